@@ -4,7 +4,14 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -15,10 +22,85 @@ import (
 	"github.com/prologic/release-notes/notes"
 )
 
+// defaultGitHubURL is the web URL of github.com, as opposed to that of a GitHub
+// Enterprise instance.
+const defaultGitHubURL = "https://github.com"
+
+// dateLayout is the layout of the release dates in changelogs.
+const dateLayout = "2006-01-02"
+
+// The output formats of the release notes.
+const (
+	// formatMarkdown is the categorized document rendered as Markdown.
+	formatMarkdown = "markdown"
+
+	// formatJSON is the categorized document as JSON.
+	formatJSON = "json"
+
+	// formatJSONNotes is the list of release notes as JSON, before they are
+	// categorized.
+	formatJSONNotes = "json-notes"
+
+	// formatHTML is the categorized document as HTML.
+	formatHTML = "html"
+
+	// formatChangelog is the section of a Keep a Changelog file for the
+	// release.
+	formatChangelog = "changelog"
+)
+
+// The formats of the log.
+const (
+	logFormatLogfmt = "logfmt"
+	logFormatJSON   = "json"
+)
+
 type options struct {
 	githubToken string
-	startSHA    string
-	endSHA      string
+	githubURL   string
+	org         string
+	repo        string
+	branch      string
+	startRev    string
+	endRev      string
+	concurrency int
+	maxRetries  int
+	cacheDir    string
+	format      string
+	template    string
+	config      string
+	noteText    notes.NoteText
+	auditReport string
+
+	// logLevel filters the log, and logFormat is the format it is written in
+	logLevel  level.Option
+	logFormat string
+
+	// strict aborts on unexpected GitHub API errors rather than leaving out the
+	// notes they affect
+	strict bool
+
+	// dependencyUpdates collects the notes of the excluded authors, such as
+	// bots, in a section rather than leaving them out
+	dependencyUpdates bool
+
+	// htmlFragment and htmlTheme are only used by the html format
+	htmlFragment bool
+	htmlTheme    string
+
+	// version, date and changelog are only used by the changelog format
+	version   string
+	date      time.Time
+	changelog string
+
+	// publish, draft, dryRun and releaseTag are only used when publishing the
+	// notes as a GitHub Release, whose tag is created at releaseTarget, the
+	// commit of the end revision, if it doesn't exist yet
+	publish       bool
+	draft         bool
+	dryRun        bool
+	releaseTag    string
+	releaseTarget string
 }
 
 func parseOptions(args []string) (*options, error) {
@@ -32,19 +114,221 @@ func parseOptions(args []string) (*options, error) {
 			"A personal GitHub access token (required)",
 		)
 
-		// flStartSHA contains the commit SHA where the release note generation
-		// begins.
+		// flGitHubURL contains the base URL of the GitHub web interface. It is
+		// only set for GitHub Enterprise instances.
+		flGitHubURL = flagset.String(
+			"github-url",
+			env.String("GITHUB_URL", defaultGitHubURL),
+			"The base URL of the GitHub (Enterprise) instance hosting the repository",
+		)
+
+		// flOrg contains the GitHub organization that owns the repository.
+		flOrg = flagset.String(
+			"org",
+			env.String("GITHUB_ORG", "netdata"),
+			"The GitHub organization (or user) that owns the repository",
+		)
+
+		// flRepo contains the name of the repository to scrape.
+		flRepo = flagset.String(
+			"repo",
+			env.String("GITHUB_REPO", "netdata"),
+			"The GitHub repository to generate release notes for",
+		)
+
+		// flBranch contains the branch that HEAD refers to in revisions.
+		flBranch = flagset.String(
+			"branch",
+			env.String("GITHUB_BRANCH", "master"),
+			"The branch that HEAD refers to in -start-rev and -end-rev",
+		)
+
+		// flStartRev contains the revision where the release note generation
+		// begins. When it is empty, the semver tag preceding the end revision is
+		// used.
+		flStartRev = flagset.String(
+			"start-rev",
+			env.String("START_REV", ""),
+			"The revision (commit hash, tag, branch or ref~N) to start at. Defaults to the previous semver tag",
+		)
+
+		// flEndRev contains the revision where the release note generation ends.
+		flEndRev = flagset.String(
+			"end-rev",
+			env.String("END_REV", ""),
+			"The revision (commit hash, tag, branch or ref~N) to end at (required)",
+		)
+
+		// flStartSHA and flEndSHA are the deprecated predecessors of flStartRev
+		// and flEndRev, kept so that existing pipelines keep working.
 		flStartSHA = flagset.String(
 			"start-sha",
 			env.String("START_SHA", ""),
-			"The commit hash to start at",
+			"Deprecated: use -start-rev",
 		)
-
-		// flEndSHA contains the commit SHA where the release note generation ends.
 		flEndSHA = flagset.String(
 			"end-sha",
 			env.String("END_SHA", ""),
-			"The commit hash to end at",
+			"Deprecated: use -end-rev",
+		)
+
+		// flConcurrency contains the number of commits processed in parallel.
+		flConcurrency = flagset.Int(
+			"concurrency",
+			env.Int("CONCURRENCY", 4),
+			"The number of commits to fetch PRs and issues for in parallel",
+		)
+
+		// flMaxRetries contains how many times a GitHub API request is retried.
+		flMaxRetries = flagset.Int(
+			"max-retries",
+			env.Int("MAX_RETRIES", 5),
+			"The number of times a GitHub API request is retried after a server error or a secondary rate limit",
+		)
+
+		// flCacheDir contains the directory of the on-disk cache of commits, PRs
+		// and issues.
+		flCacheDir = flagset.String(
+			"cache-dir",
+			env.String("CACHE_DIR", defaultCacheDir()),
+			"The directory to cache commits, PRs and issues in between runs",
+		)
+
+		// flNoCache disables the on-disk cache.
+		flNoCache = flagset.Bool(
+			"no-cache",
+			env.Bool("NO_CACHE", false),
+			"Download everything from GitHub instead of using the on-disk cache",
+		)
+
+		// flFormat contains the output format of the release notes.
+		flFormat = flagset.String(
+			"format",
+			env.String("FORMAT", formatMarkdown),
+			"The output format: markdown, json (the categorized document), json-notes (the list of notes), html or changelog",
+		)
+
+		// flTemplate contains the path of a text/template that the document is
+		// rendered with instead of the default Markdown layout.
+		flTemplate = flagset.String(
+			"template",
+			env.String("TEMPLATE", ""),
+			"The path of a Go text/template to render the notes with instead of the default Markdown layout",
+		)
+
+		// flConfig contains the path of a JSON file that declares the sections of
+		// the document and the labels that put notes in them.
+		flConfig = flagset.String(
+			"config",
+			env.String("CONFIG", ""),
+			"The path of a JSON file that configures the sections of the notes and their labels",
+		)
+
+		// flNoteText contains where the text of the release notes comes from.
+		flNoteText = flagset.String(
+			"note-text",
+			env.String("NOTE_TEXT", string(notes.NoteTextTitle)),
+			"Where the text of the notes comes from: block (the release-note block of the PR), title (the PR title), commit (the first line of the commit message) or block-or-title",
+		)
+
+		// flAuditReport contains the path of a report of what happened to every
+		// commit in the range.
+		flAuditReport = flagset.String(
+			"audit-report",
+			env.String("AUDIT_REPORT", ""),
+			"The path of a report that explains why each commit was included or left out, as JSON if it ends with .json and as a Markdown table otherwise",
+		)
+
+		// flLogLevel contains the lowest level of the log messages to write.
+		flLogLevel = flagset.String(
+			"log-level",
+			env.String("LOG_LEVEL", "info"),
+			"The lowest level of the log messages to write: debug, info, warn or error",
+		)
+
+		// flLogFormat contains the format of the log.
+		flLogFormat = flagset.String(
+			"log-format",
+			env.String("LOG_FORMAT", logFormatLogfmt),
+			"The format of the log: logfmt or json",
+		)
+
+		// flStrict aborts on unexpected GitHub API errors.
+		flStrict = flagset.Bool(
+			"strict",
+			env.Bool("STRICT", false),
+			"Abort on unexpected GitHub API errors rather than leaving out the notes of the commits they affect",
+		)
+
+		// flDependencyUpdates collects the notes of bots in a collapsed section.
+		flDependencyUpdates = flagset.Bool(
+			"dependency-updates",
+			env.Bool("DEPENDENCY_UPDATES", false),
+			"Collect the notes of the excluded authors, such as dependency update bots, in a collapsed section rather than leaving them out",
+		)
+
+		// flHTMLFragment renders an HTML fragment rather than a standalone page.
+		flHTMLFragment = flagset.Bool(
+			"html-fragment",
+			env.Bool("HTML_FRAGMENT", false),
+			"Render the html format as a fragment to embed in another page rather than as a standalone page",
+		)
+
+		// flHTMLTheme contains the stylesheet embedded in the HTML.
+		flHTMLTheme = flagset.String(
+			"html-theme",
+			env.String("HTML_THEME", "default"),
+			"The stylesheet to embed in the html format: default, none, or the path of a CSS file",
+		)
+
+		// flVersion contains the version that the changelog section is for.
+		flVersion = flagset.String(
+			"version",
+			env.String("VERSION", ""),
+			"The version of the release in the changelog format, or Unreleased. Defaults to -end-rev without its v prefix",
+		)
+
+		// flDate contains the release date of the changelog section.
+		flDate = flagset.String(
+			"date",
+			env.String("RELEASE_DATE", ""),
+			"The date of the release in the changelog format, as YYYY-MM-DD. Defaults to today",
+		)
+
+		// flChangelog contains the path of the changelog file that the section
+		// is inserted into.
+		flChangelog = flagset.String(
+			"changelog",
+			env.String("CHANGELOG", ""),
+			"The path of a CHANGELOG.md file to insert the release into, rather than printing it, with the changelog format",
+		)
+
+		// flPublish publishes the notes as a GitHub Release.
+		flPublish = flagset.Bool(
+			"publish",
+			env.Bool("RELEASE_NOTES_PUBLISH", false),
+			"Create or update the GitHub Release for the release tag with the notes, rather than printing them",
+		)
+
+		// flDraft creates the GitHub Release as a draft.
+		flDraft = flagset.Bool(
+			"draft",
+			env.Bool("DRAFT", false),
+			"Create the GitHub Release as a draft with -publish",
+		)
+
+		// flDryRun previews what -publish would do.
+		flDryRun = flagset.Bool(
+			"dry-run",
+			env.Bool("DRY_RUN", false),
+			"Print the notes and what -publish would do, without changing the GitHub Release",
+		)
+
+		// flReleaseTag contains the tag of the GitHub Release.
+		flReleaseTag = flagset.String(
+			"release-tag",
+			env.String("RELEASE_TAG", ""),
+			"The tag of the GitHub Release to publish with -publish. Defaults to -end-rev, which is required to be a tag then",
 		)
 	)
 
@@ -58,28 +342,288 @@ func parseOptions(args []string) (*options, error) {
 		return nil, errors.New("GitHub token must be set via -github-token or $GITHUB_TOKEN")
 	}
 
-	// The start SHA is required.
-	if *flStartSHA == "" {
-		return nil, errors.New("The starting commit hash must be set via -start-sha or $START_SHA")
+	// The repository and its branch are required.
+	if *flOrg == "" || *flRepo == "" {
+		return nil, errors.New("The repository must be set via -org and -repo or $GITHUB_ORG and $GITHUB_REPO")
+	}
+	if *flBranch == "" {
+		return nil, errors.New("The branch must be set via -branch or $GITHUB_BRANCH")
+	}
+
+	if *flStartRev == "" {
+		*flStartRev = *flStartSHA
+	}
+	if *flEndRev == "" {
+		*flEndRev = *flEndSHA
+	}
+
+	// The end revision is required.
+	if *flEndRev == "" {
+		return nil, errors.New("The ending revision must be set via -end-rev or $END_REV")
+	}
+
+	switch *flFormat {
+	case formatMarkdown, formatJSON, formatJSONNotes, formatHTML, formatChangelog:
+	default:
+		return nil, fmt.Errorf("Unknown output format %q, must be one of markdown, json, json-notes, html or changelog", *flFormat)
+	}
+
+	// The changelog file can only be updated with the changelog format.
+	if *flChangelog != "" && *flFormat != formatChangelog {
+		return nil, errors.New("The changelog can only be updated with the changelog format")
+	}
+
+	// Releases are published with the Markdown notes.
+	if *flPublish && *flFormat != formatMarkdown {
+		return nil, errors.New("Releases can only be published with the markdown format")
+	}
+	if *flDryRun && !*flPublish {
+		return nil, errors.New("A dry run is only possible with -publish")
+	}
+	// The date is only part of the changelog format.
+	var date time.Time
+	if *flFormat == formatChangelog {
+		date = time.Now()
+		if *flDate != "" {
+			var err error
+			date, err = time.Parse(dateLayout, *flDate)
+			if err != nil {
+				return nil, fmt.Errorf("The date must be formatted as YYYY-MM-DD: %v", err)
+			}
+		}
+	}
+	if *flVersion == "" {
+		*flVersion = strings.TrimPrefix(*flEndRev, "v")
+	}
+
+	htmlTheme, err := loadHTMLTheme(*flHTMLTheme)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates render the document as text, in place of the Markdown layout.
+	if *flTemplate != "" && *flFormat != formatMarkdown {
+		return nil, errors.New("A template can only be used with the markdown format")
+	}
+
+	noteText, err := notes.ParseNoteText(*flNoteText)
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel, err := parseLogLevel(*flLogLevel)
+	if err != nil {
+		return nil, err
+	}
+	switch *flLogFormat {
+	case logFormatLogfmt, logFormatJSON:
+	default:
+		return nil, fmt.Errorf("Unknown log format %q, must be logfmt or json", *flLogFormat)
 	}
 
-	// The end SHA is required.
-	if *flEndSHA == "" {
-		return nil, errors.New("The ending commit hash must be set via -end-sha or $END_SHA")
+	// An empty cache directory disables the cache.
+	if *flNoCache {
+		*flCacheDir = ""
 	}
 
 	return &options{
 		githubToken: *flGitHubToken,
-		startSHA:    *flStartSHA,
-		endSHA:      *flEndSHA,
+		githubURL:   strings.TrimRight(*flGitHubURL, "/"),
+		org:         *flOrg,
+		repo:        *flRepo,
+		branch:      *flBranch,
+		startRev:    *flStartRev,
+		endRev:      *flEndRev,
+		concurrency: *flConcurrency,
+		maxRetries:  *flMaxRetries,
+		cacheDir:    *flCacheDir,
+		format:      *flFormat,
+		template:    *flTemplate,
+		config:      *flConfig,
+		noteText:    noteText,
+		auditReport: *flAuditReport,
+		strict:      *flStrict,
+
+		logLevel:  logLevel,
+		logFormat: *flLogFormat,
+
+		dependencyUpdates: *flDependencyUpdates,
+
+		htmlFragment: *flHTMLFragment,
+		htmlTheme:    htmlTheme,
+
+		version:   *flVersion,
+		date:      date,
+		changelog: *flChangelog,
+
+		publish:    *flPublish,
+		draft:      *flDraft,
+		dryRun:     *flDryRun,
+		releaseTag: *flReleaseTag,
 	}, nil
 }
 
+// loadHTMLTheme returns the stylesheet named by the -html-theme flag.
+func loadHTMLTheme(theme string) (string, error) {
+	switch theme {
+	case "default":
+		return notes.DefaultHTMLTheme, nil
+	case "none", "":
+		return "", nil
+	}
+	css, err := ioutil.ReadFile(theme)
+	if err != nil {
+		return "", fmt.Errorf("Error reading the HTML theme: %v", err)
+	}
+	return string(css), nil
+}
+
+// writeChangelog renders the changelog section of the release, and inserts it
+// into the changelog file if there is one, or prints it otherwise.
+func writeChangelog(doc *notes.Document, opts *options) error {
+	if opts.changelog == "" {
+		return notes.RenderChangelog(doc, opts.version, opts.date, os.Stdout)
+	}
+
+	section := &strings.Builder{}
+	if err := notes.RenderChangelog(doc, opts.version, opts.date, section); err != nil {
+		return err
+	}
+	changelog, err := ioutil.ReadFile(opts.changelog)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(opts.changelog, []byte(notes.InsertChangelog(string(changelog), section.String())), 0644)
+}
+
+// writeAuditReport writes the records of the audit to the audit report, as
+// JSON or as a Markdown table depending on its extension.
+func writeAuditReport(audit *notes.Audit, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = notes.RenderAuditJSON(audit.Records(), f)
+	} else {
+		err = notes.RenderAuditMarkdown(audit.Records(), f)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// publishRelease creates or updates the GitHub Release for the release tag with
+// the notes rendered through the template. A dry run prints the notes instead.
+func publishRelease(
+	ctx context.Context,
+	client *github.Client,
+	doc *notes.Document,
+	tmpl *template.Template,
+	opts *options,
+	logger log.Logger,
+) error {
+	body := &strings.Builder{}
+	if err := notes.RenderTemplate(doc, tmpl, body); err != nil {
+		return err
+	}
+
+	action, release, err := notes.PublishRelease(
+		client,
+		&notes.Release{
+			Tag:    opts.releaseTag,
+			Body:   body.String(),
+			Target: opts.releaseTarget,
+			Draft:  opts.draft,
+		},
+		opts.dryRun,
+		notes.WithContext(ctx),
+		notes.WithOrg(opts.org),
+		notes.WithRepo(opts.repo),
+		notes.WithBranch(opts.branch),
+	)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		level.Info(logger).Log("msg", "dry run, the GitHub Release would be "+string(action), "tag", opts.releaseTag)
+		_, err := io.WriteString(os.Stdout, body.String())
+		return err
+	}
+	level.Info(logger).Log("msg", "GitHub Release "+string(action), "tag", opts.releaseTag, "url", release.GetHTMLURL())
+	return nil
+}
+
+// defaultCacheDir returns the release-notes directory in the user's cache
+// directory, or nothing if there isn't one, which disables the cache.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "release-notes")
+}
+
+// parseLogLevel parses the name of the lowest level of the log messages to
+// write.
+func parseLogLevel(s string) (level.Option, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return level.AllowDebug(), nil
+	case "info":
+		return level.AllowInfo(), nil
+	case "warn":
+		return level.AllowWarn(), nil
+	case "error":
+		return level.AllowError(), nil
+	}
+	return nil, fmt.Errorf("Unknown log level %q, must be one of debug, info, warn or error", s)
+}
+
+// newLogger creates a logger that writes to w in the given format, and leaves
+// out the messages below the given level. Messages without a level are logged
+// at the debug level.
+func newLogger(w io.Writer, format string, allow level.Option) log.Logger {
+	var logger log.Logger
+	if format == logFormatJSON {
+		logger = log.NewJSONLogger(log.NewSyncWriter(w))
+	} else {
+		logger = log.NewLogfmtLogger(log.NewSyncWriter(w))
+	}
+	logger = level.NewFilter(logger, allow)
+	return level.NewInjector(logger, level.DebugValue())
+}
+
+// loadConfig loads the configuration at path, or returns the default
+// configuration if path is empty.
+func loadConfig(path string) (*notes.Config, error) {
+	if path == "" {
+		return notes.DefaultConfig(), nil
+	}
+	return notes.LoadConfig(path)
+}
+
+// parseTemplate parses the document template at path, or returns the default
+// Markdown template if path is empty.
+func parseTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return notes.ParseTemplate(notes.DefaultTemplate)
+	}
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return notes.ParseTemplate(string(text))
+}
+
 func main() {
 	// Use the go-kit structured logger for logging. To learn more about structured
 	// logging see: https://github.com/go-kit/kit/tree/master/log#structured-logging
-	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-	logger = level.NewInjector(logger, level.DebugValue())
+	logger := newLogger(os.Stderr, logFormatLogfmt, level.AllowInfo())
 
 	// Parse the CLI options and enforce required defaults
 	opts, err := parseOptions(os.Args[1:])
@@ -87,36 +631,166 @@ func main() {
 		level.Error(logger).Log("msg", "error parsing options", "err", err)
 		os.Exit(1)
 	}
+	logger = newLogger(os.Stderr, opts.logFormat, opts.logLevel)
+
+	// Parse the template up front, rather than failing after all of the API
+	// requests have been made
+	tmpl, err := parseTemplate(opts.template)
+	if err != nil {
+		level.Error(logger).Log("msg", "error parsing the template", "template", opts.template, "err", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig(opts.config)
+	if err != nil {
+		level.Error(logger).Log("msg", "error loading the config", "config", opts.config, "err", err)
+		os.Exit(1)
+	}
+	if opts.dependencyUpdates {
+		config.Sections = append(config.Sections, notes.DependencyUpdatesSection())
+	}
 
 	// Create the GitHub API client
 	ctx := context.Background()
 	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: opts.githubToken},
 	))
+
+	// Wait out rate limits and retry transient errors rather than failing midway
+	httpClient.Transport = notes.NewRateLimitTransport(httpClient.Transport, logger, opts.maxRetries)
 	githubClient := github.NewClient(httpClient)
+	if opts.githubURL != defaultGitHubURL {
+		// GitHub Enterprise serves its API from the same host as the web interface
+		githubClient, err = github.NewEnterpriseClient(
+			opts.githubURL+"/api/v3/",
+			opts.githubURL+"/api/uploads/",
+			httpClient,
+		)
+		if err != nil {
+			level.Error(logger).Log("msg", "error creating the GitHub Enterprise client", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Fetch commits, PRs and issues from GitHub
+	source := notes.NewGitHubSource(githubClient, opts.cacheDir)
+
+	// Without a start revision, generate the notes since the previous release
+	if opts.startRev == "" {
+		opts.startRev, err = notes.PreviousTag(
+			source, opts.endRev,
+			notes.WithContext(ctx),
+			notes.WithOrg(opts.org),
+			notes.WithRepo(opts.repo),
+			notes.WithBranch(opts.branch),
+			notes.WithWebURL(opts.githubURL),
+		)
+		if err != nil {
+			level.Error(logger).Log("msg", "error finding the previous tag", "err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "starting from the previous tag", "tag", opts.startRev)
+	}
+
+	// Releases are published for a tag, so without -release-tag, the end
+	// revision has to be one
+	if opts.publish {
+		if opts.releaseTag == "" {
+			tags, err := source.ListTags(ctx, opts.org, opts.repo)
+			if err != nil {
+				level.Error(logger).Log("msg", "error listing the tags", "err", err)
+				os.Exit(1)
+			}
+			if !notes.HasString(tags, opts.endRev) {
+				level.Error(logger).Log("msg", "the release tag must be set via -release-tag or $RELEASE_TAG when the end revision isn't a tag", "end-rev", opts.endRev)
+				os.Exit(1)
+			}
+			opts.releaseTag = opts.endRev
+		}
+		opts.releaseTarget, err = notes.ResolveRevision(
+			source, opts.endRev,
+			notes.WithContext(ctx),
+			notes.WithOrg(opts.org),
+			notes.WithRepo(opts.repo),
+			notes.WithBranch(opts.branch),
+		)
+		if err != nil {
+			level.Error(logger).Log("msg", "error resolving the end revision", "err", err)
+			os.Exit(1)
+		}
+	}
 
 	// Fetch a list of fully-contextualized release notes
 	level.Info(logger).Log("msg", "fetching all commits. this might take a while...")
+	var audit *notes.Audit
+	if opts.auditReport != "" {
+		audit = notes.NewAudit()
+	}
 	releaseNotes, err := notes.ListReleaseNotes(
-		githubClient, logger, opts.startSHA, opts.endSHA,
+		source, logger, opts.startRev, opts.endRev,
 		notes.WithContext(ctx),
-		notes.WithOrg("netdata"),
-		notes.WithRepo("netdata"),
+		notes.WithOrg(opts.org),
+		notes.WithRepo(opts.repo),
+		notes.WithBranch(opts.branch),
+		notes.WithWebURL(opts.githubURL),
+		notes.WithConcurrency(opts.concurrency),
+		notes.WithConfig(config),
+		notes.WithNoteText(opts.noteText),
+		notes.WithAudit(audit),
+		notes.WithStrict(opts.strict),
 	)
 	if err != nil {
 		level.Error(logger).Log("msg", "error generating release notes", "err", err)
 		os.Exit(1)
 	}
+	if audit != nil {
+		if err := writeAuditReport(audit, opts.auditReport); err != nil {
+			level.Error(logger).Log("msg", "error writing the audit report", "path", opts.auditReport, "err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "wrote the audit report", "path", opts.auditReport)
+	}
 	level.Info(logger).Log("msg", "got the commits, performing rendering")
 
-	doc, err := notes.CreateDocument(releaseNotes)
+	if opts.format == formatJSONNotes {
+		if err := notes.RenderNotesJSON(releaseNotes, os.Stdout); err != nil {
+			level.Error(logger).Log("msg", "error rendering release notes to json", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	doc, err := notes.CreateDocument(releaseNotes, notes.WithConfig(config))
 	if err != nil {
 		level.Error(logger).Log("msg", "error creating release note document", "err", err)
 		os.Exit(1)
 	}
 
-	if err := notes.RenderMarkdown(doc, os.Stdout); err != nil {
-		level.Error(logger).Log("msg", "error rendering release note document to markdown", "err", err)
+	if opts.publish {
+		if err := publishRelease(ctx, githubClient, doc, tmpl, opts, logger); err != nil {
+			level.Error(logger).Log("msg", "error publishing the GitHub Release", "tag", opts.releaseTag, "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch opts.format {
+	case formatJSON:
+		err = notes.RenderJSON(doc, os.Stdout)
+	case formatChangelog:
+		err = writeChangelog(doc, opts)
+	case formatHTML:
+		err = notes.RenderHTML(
+			doc, os.Stdout,
+			notes.WithHTMLTitle(fmt.Sprintf("%s/%s %s", opts.org, opts.repo, opts.endRev)),
+			notes.WithHTMLFragment(opts.htmlFragment),
+			notes.WithHTMLTheme(opts.htmlTheme),
+		)
+	default:
+		err = notes.RenderTemplate(doc, tmpl, os.Stdout)
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "error rendering release note document", "format", opts.format, "err", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,176 @@
+package notes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// Decision is what happened to a commit on its way to the release notes.
+type Decision string
+
+const (
+	// DecisionIncluded means that the commit has a note in the release notes.
+	DecisionIncluded Decision = "included"
+
+	// DecisionExcluded means that the commit has no release note, such as
+	// because it has no PR or its PR opted out of the release notes.
+	DecisionExcluded Decision = "excluded"
+
+	// DecisionDedup means that the note of the commit was left out because
+	// another commit has a note with the same text.
+	DecisionDedup Decision = "dedup"
+
+	// DecisionBot means that the commit or its PR was authored by one of the
+	// excluded authors of the configuration.
+	DecisionBot Decision = "bot"
+
+	// DecisionError means that the note of the commit couldn't be produced.
+	DecisionError Decision = "error"
+)
+
+// AuditRecord explains what happened to a commit on its way to the release
+// notes.
+type AuditRecord struct {
+	// Commit is the SHA of the commit.
+	Commit string `json:"commit"`
+
+	// Title is the first line of the commit message.
+	Title string `json:"title"`
+
+	// PrNumber is the number of the PR of the commit, if it has one.
+	PrNumber int `json:"pr_number,omitempty"`
+
+	// Issues refer to the issues that the PR closes, such as "#123", or
+	// "org/repo#123" for those of other repositories.
+	Issues []string `json:"issues,omitempty"`
+
+	Decision Decision `json:"decision"`
+
+	// Reason explains the decision, unless the commit was included.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Audit records what happened to every commit in the range of the release
+// notes, so that it can be verified that nothing was lost. It is passed to
+// ListReleaseNotes or ListCommitsWithNotes with WithAudit. It is safe for
+// concurrent use, and a nil *Audit records nothing.
+type Audit struct {
+	mu      sync.Mutex
+	records []*AuditRecord
+	bySHA   map[string]*AuditRecord
+}
+
+// NewAudit creates an empty Audit.
+func NewAudit() *Audit {
+	return &Audit{
+		bySHA: map[string]*AuditRecord{},
+	}
+}
+
+// Records returns copies of the records of the audit, in commit order.
+func (a *Audit) Records() []*AuditRecord {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	records := make([]*AuditRecord, len(a.records))
+	for i, record := range a.records {
+		r := *record
+		records[i] = &r
+	}
+	return records
+}
+
+// add starts a record for every commit that doesn't have one yet.
+func (a *Audit) add(commits []*github.RepositoryCommit) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, commit := range commits {
+		if _, ok := a.bySHA[commit.GetSHA()]; ok {
+			continue
+		}
+		record := &AuditRecord{
+			Commit: commit.GetSHA(),
+			Title:  strings.TrimSpace(strings.SplitN(commit.GetCommit().GetMessage(), "\n", 2)[0]),
+		}
+		a.records = append(a.records, record)
+		a.bySHA[record.Commit] = record
+	}
+}
+
+// update changes the record of a commit, if there is one.
+func (a *Audit) update(sha string, fn func(*AuditRecord)) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if record, ok := a.bySHA[sha]; ok {
+		fn(record)
+	}
+}
+
+// decide records the decision for a commit, and the reason for it.
+func (a *Audit) decide(sha string, decision Decision, reason string) {
+	a.update(sha, func(r *AuditRecord) {
+		r.Decision = decision
+		r.Reason = reason
+	})
+}
+
+// RenderAuditJSON writes the records of an audit to the supplied io.Writer as
+// a JSON array.
+func RenderAuditJSON(records []*AuditRecord, w io.Writer) error {
+	if records == nil {
+		records = []*AuditRecord{}
+	}
+	return writeJSON(records, w)
+}
+
+// RenderAuditMarkdown writes the records of an audit to the supplied io.Writer
+// as a Markdown table, with a row per commit.
+func RenderAuditMarkdown(records []*AuditRecord, w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("| Commit | Title | PR | Issues | Decision | Reason |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, r := range records {
+		sha := r.Commit
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n",
+			sha,
+			markdownCell(r.Title),
+			auditNumber(r.PrNumber),
+			strings.Join(r.Issues, ", "),
+			r.Decision,
+			markdownCell(r.Reason),
+		)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text for a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// auditNumber formats the number of a PR, or nothing if it is 0.
+func auditNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%d", n)
+}
@@ -0,0 +1,159 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/kolide/kit/logutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	logger := logutil.NewCLILogger(true)
+
+	audit := NewAudit()
+	notes, err := ListReleaseNotes(source, logger, "v1.0.0", "v1.1.0", WithAudit(audit))
+	require.NoError(t, err)
+
+	// every commit in the range has a record, in commit order
+	records := audit.Records()
+	require.Len(t, records, 12)
+	require.Equal(t, featureCommit, records[0].Commit)
+	require.Equal(t, "Add Prometheus remote write exporter (#101)", records[0].Title)
+
+	decisions := map[string]*AuditRecord{}
+	included := 0
+	for _, record := range records {
+		decisions[record.Commit] = record
+		if record.Decision == DecisionIncluded {
+			included++
+			require.Empty(t, record.Reason)
+		} else {
+			require.NotEmpty(t, record.Reason)
+		}
+	}
+	require.Equal(t, len(notes), included)
+
+	require.Equal(t, &AuditRecord{
+		Commit:   featureCommit,
+		Title:    "Add Prometheus remote write exporter (#101)",
+		PrNumber: 101,
+		Issues:   []string{"#90"},
+		Decision: DecisionIncluded,
+	}, decisions[featureCommit])
+
+	require.Equal(t, DecisionExcluded, decisions[mergeCommit].Decision)
+	require.Equal(t, "no PR found", decisions[mergeCommit].Reason)
+	require.Zero(t, decisions[mergeCommit].PrNumber)
+
+	require.Equal(t, DecisionExcluded, decisions[noChangelogCommit].Decision)
+	require.Equal(t, []string{"#92"}, decisions[noChangelogCommit].Issues)
+	require.Equal(t, `PR #106 has the skip label "no changelog"`, decisions[noChangelogCommit].Reason)
+
+	require.Equal(t, DecisionExcluded, decisions[noneCommit].Decision)
+	require.Equal(t, "PR #109 opted out with a NONE release-note block", decisions[noneCommit].Reason)
+
+	require.Equal(t, DecisionBot, decisions[botCommit].Decision)
+	require.Contains(t, decisions[botCommit].Reason, "netdatabot")
+}
+
+func TestAuditDedupAndErrors(t *testing.T) {
+	source := newFakeSource(
+		map[string][]string{"c1": nil, "c2": {"c1"}, "c3": {"c2"}, "c4": {"c3"}},
+		map[string]string{"v1.0.0": "c1", "v1.1.0": "c4"},
+	)
+	// the PRs of c2 and c3 have the same title, and c4 has no PR at all
+	for sha, number := range map[string]int{"c2": 2, "c3": 3} {
+		source.prs[sha] = &github.PullRequest{
+			Number: github.Int(number),
+			Title:  github.String("Fix the build"),
+			User:   &github.User{Login: github.String("alice")},
+		}
+	}
+
+	audit := NewAudit()
+	notes, err := ListReleaseNotes(source, logutil.NewCLILogger(true), "v1.0.0", "v1.1.0", WithAudit(audit), WithConcurrency(1))
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+
+	records := audit.Records()
+	require.Len(t, records, 3)
+	require.Equal(t, DecisionIncluded, records[0].Decision)
+	require.Equal(t, DecisionDedup, records[1].Decision)
+	require.Equal(t, "same note as commit c2", records[1].Reason)
+	require.Equal(t, 3, records[1].PrNumber)
+	require.Equal(t, DecisionExcluded, records[2].Decision)
+	require.Equal(t, "no PR found", records[2].Reason)
+
+	// a nil audit records nothing
+	var nilAudit *Audit
+	require.Nil(t, nilAudit.Records())
+	_, err = ListReleaseNotes(source, logutil.NewCLILogger(true), "v1.0.0", "v1.1.0", WithAudit(nilAudit))
+	require.NoError(t, err)
+
+	// commits without a PR aren't errors, even in strict mode
+	_, err = ListReleaseNotes(source, logutil.NewCLILogger(true), "v1.0.0", "v1.1.0", WithStrict(true))
+	require.NoError(t, err)
+
+	// but failing to get the PR of c4 is
+	source.prErrs["c4"] = errors.New("connection reset by peer")
+	audit = NewAudit()
+	notes, err = ListReleaseNotes(source, logutil.NewCLILogger(true), "v1.0.0", "v1.1.0", WithAudit(audit), WithConcurrency(1))
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	records = audit.Records()
+	require.Equal(t, DecisionError, records[2].Decision)
+	require.Contains(t, records[2].Reason, "connection reset by peer")
+
+	// which aborts the run in strict mode
+	_, err = ListReleaseNotes(source, logutil.NewCLILogger(true), "v1.0.0", "v1.1.0", WithStrict(true))
+	require.EqualError(t, err, "connection reset by peer")
+}
+
+func TestRenderAudit(t *testing.T) {
+	records := []*AuditRecord{
+		{
+			Commit:   featureCommit,
+			Title:    "Add Prometheus remote write exporter (#101)",
+			PrNumber: 101,
+			Issues:   []string{"#90", "netdata/go.d.plugin#55"},
+			Decision: DecisionIncluded,
+		},
+		{
+			Commit:   sigCommit,
+			Title:    "Speed up the web server | static files (#111)",
+			PrNumber: 111,
+			Decision: DecisionDedup,
+			Reason:   "same note as commit " + featureCommit,
+		},
+		{
+			Commit:   mergeCommit,
+			Title:    "Merge branch 'fix-typo' into master",
+			Decision: DecisionExcluded,
+			Reason:   "no PR found",
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderAuditMarkdown(records, buf))
+	require.Equal(t, `| Commit | Title | PR | Issues | Decision | Reason |
+| --- | --- | --- | --- | --- | --- |
+| 2f22765 | Add Prometheus remote write exporter (#101) | #101 | #90, netdata/go.d.plugin#55 | included |  |
+| 9e8adf5 | Speed up the web server \| static files (#111) | #111 |  | dedup | same note as commit 2f22765d04931a078909145ca628d2264c852d7d |
+| 9f84ad6 | Merge branch 'fix-typo' into master |  |  | excluded | no PR found |
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, RenderAuditJSON(records, buf))
+	decoded := []*AuditRecord{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, records, decoded)
+
+	buf.Reset()
+	require.NoError(t, RenderAuditJSON(nil, buf))
+	require.Equal(t, "[]\n", buf.String())
+}
@@ -0,0 +1,126 @@
+package notes
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The info strings of the fenced code blocks that hold release notes.
+const (
+	// BlockReleaseNote is the info string of a user-facing release note.
+	BlockReleaseNote = "release-note"
+
+	// BlockDevReleaseNote is the info string of a release note for developers.
+	BlockDevReleaseNote = "dev-release-note"
+
+	// BlockActionRequired is the info string of a release note that requires
+	// users to take action, like the release-note-action-required label.
+	BlockActionRequired = "release-note-action-required"
+)
+
+// noteBlockInfos are the info strings of the blocks that ParseNoteBlocks
+// returns.
+var noteBlockInfos = []string{
+	BlockReleaseNote,
+	BlockDevReleaseNote,
+	BlockActionRequired,
+}
+
+// htmlCommentExp matches the HTML comments that PR templates are full of, such
+// as the instructions of the release-note block.
+var htmlCommentExp = regexp.MustCompile(`(?s)<!--.*?(-->|$)`)
+
+// NoteBlock is a fenced code block of release notes, such as
+//
+//	```release-note
+//	The text of the note
+//	```
+type NoteBlock struct {
+	// Info is the info string of the block, such as "release-note".
+	Info string
+
+	// Text is the content of the block, without HTML comments or the blank
+	// lines around it. Its lines are separated by "\n".
+	Text string
+}
+
+// ParseNoteBlocks parses the release-note blocks of a string which may contain
+// the commit message, the PR description, etc. Blocks are fenced with ``` or
+// ~~~, with one of the info strings of release notes, and end at the closing
+// fence or at the end of the string. Any line ending is accepted, and the HTML
+// comments of PR templates are stripped. Other fenced code blocks are skipped,
+// so that release-note fences quoted in them don't count.
+func ParseNoteBlocks(s string) []*NoteBlock {
+	s = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s)
+	s = htmlCommentExp.ReplaceAllString(s, "")
+
+	blocks := []*NoteBlock{}
+	var (
+		fence string
+		block *NoteBlock
+		lines []string
+	)
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			fence = openingFence(trimmed)
+			if fence == "" {
+				continue
+			}
+			info := strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+			if fields := strings.Fields(info); len(fields) > 0 && HasString(noteBlockInfos, strings.ToLower(fields[0])) {
+				block = &NoteBlock{Info: strings.ToLower(fields[0])}
+				lines = nil
+			}
+			continue
+		}
+
+		// a fence is closed by a fence of the same character that is at least
+		// as long, without an info string
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			if block != nil {
+				block.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+				blocks = append(blocks, block)
+			}
+			fence, block = "", nil
+			continue
+		}
+		if block != nil {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+	}
+
+	// an unclosed block runs to the end of the string
+	if block != nil {
+		block.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// openingFence returns the fence that a line opens a fenced code block with,
+// such as "```", or nothing if it doesn't.
+func openingFence(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			fence := line[:n]
+			// the info string of a backtick fence can't contain backticks
+			if c == "`" && strings.Contains(line[n:], "`") {
+				return ""
+			}
+			return fence
+		}
+	}
+	return ""
+}
+
+// hasNoteBlock reports whether there is a block with the given info string.
+func hasNoteBlock(blocks []*NoteBlock, info string) bool {
+	for _, block := range blocks {
+		if block.Info == info {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNoteBlocks(t *testing.T) {
+	cases := map[string]struct {
+		body     string
+		expected []*NoteBlock
+	}{
+		"crlf": {
+			body:     "Fixes #90\r\n\r\n```release-note\r\nAdd an exporter\r\n```\r\n",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter"}},
+		},
+		"lf": {
+			body:     "```release-note\nAdd an exporter\n```",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter"}},
+		},
+		"cr": {
+			body:     "```release-note\rAdd an exporter\r```",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter"}},
+		},
+		"multi-line": {
+			body:     "```release-note\r\n\r\nAdd an exporter\r\nfor Prometheus   \r\n\r\n```",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter\nfor Prometheus"}},
+		},
+		"info strings": {
+			body: "```dev-release-note\nUse Go modules\n```\n" +
+				"``` Release-Note-Action-Required\nRename the option\n```\n" +
+				"~~~release-note\nAdd an exporter\n~~~",
+			expected: []*NoteBlock{
+				{Info: BlockDevReleaseNote, Text: "Use Go modules"},
+				{Info: BlockActionRequired, Text: "Rename the option"},
+				{Info: BlockReleaseNote, Text: "Add an exporter"},
+			},
+		},
+		"html comments": {
+			body: "<!-- Write your release note:\n```release-note\nNONE\n```\n-->\n" +
+				"```release-note\n<!-- Enter NONE if there is no user-facing change -->\nAdd an exporter\n```",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter"}},
+		},
+		"empty": {
+			body:     "```release-note\n<!-- Describe the change -->\n```",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: ""}},
+		},
+		"unclosed": {
+			body:     "```release-note\nAdd an exporter\n",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Add an exporter"}},
+		},
+		"longer fence": {
+			body:     "````release-note\nQuote ``` in a note\n```\n````",
+			expected: []*NoteBlock{{Info: BlockReleaseNote, Text: "Quote ``` in a note\n```"}},
+		},
+		"other code blocks": {
+			body:     "```\nNot a note\n```\n```sh\n```release-note\nNot a note either\n```",
+			expected: []*NoteBlock{},
+		},
+		"inline code": {
+			body:     "Run ```release-note``` to see",
+			expected: []*NoteBlock{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ParseNoteBlocks(tc.body))
+		})
+	}
+}
+
+func TestNoteTextFromString(t *testing.T) {
+	text, err := NoteTextFromString("```release-note\r\n* [Action required] Rename the option\r\n```\r\n\r\n```dev-release-note\r\n```\r\n\r\n```release-note\r\nUpdate the docs\r\n```")
+	require.NoError(t, err)
+	require.Equal(t, "Rename the option\nUpdate the docs", text)
+
+	// dev-release-note blocks are for developers, not users
+	text, err = NoteTextFromString("```release-note\r\nAdd an exporter\r\n```\r\n\r\n```dev-release-note\r\nThe exporters share a queue now\r\n```")
+	require.NoError(t, err)
+	require.Equal(t, "Add an exporter", text)
+
+	_, err = NoteTextFromString("```dev-release-note\r\nThe exporters share a queue now\r\n```")
+	require.True(t, errors.Is(err, ErrNoNote), err)
+
+	// a bare code block isn't a release note
+	_, err = NoteTextFromString("```\r\nAdd an exporter\r\n```")
+	require.Error(t, err)
+}
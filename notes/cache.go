@@ -0,0 +1,143 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// cacheEntry is a GitHub API object as it is stored in the on-disk cache.
+type cacheEntry struct {
+	// ETag is the entity tag GitHub returned with the object, which is used to
+	// revalidate it with a conditional request.
+	ETag string `json:"etag,omitempty"`
+
+	// Data is the JSON representation of the object.
+	Data json.RawMessage `json:"data"`
+}
+
+// diskCache stores GitHub API objects on disk so that they can be reused across
+// runs. Every object is stored in its own file, named after its key.
+type diskCache string
+
+// load returns the cached entry for key, if there is one.
+func (d diskCache) load(key string) (*cacheEntry, bool) {
+	data, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+// store writes the entry for key to disk. The entry is written to a temporary
+// file first so that concurrent runs never read a partial entry.
+func (d diskCache) store(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "error creating cache directory")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "error writing cache entry")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "error writing cache entry")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "error writing cache entry")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (d diskCache) path(key string) string {
+	return filepath.Join(string(d), filepath.FromSlash(key)+".json")
+}
+
+// getCached fetches the GitHub API object at the relative URL u into v. When the
+// on-disk cache is enabled, the object is stored under key, and a cached copy is
+// revalidated with a conditional request, which doesn't count against the rate
+// limit when the object hasn't changed.
+func (s *GitHubSource) getCached(ctx context.Context, key, u string, v interface{}) error {
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	if s.cacheDir == "" {
+		_, err := s.client.Do(ctx, req, v)
+		return err
+	}
+
+	cache := diskCache(s.cacheDir)
+	key = s.cacheKey(key)
+	cached, ok := cache.load(key)
+	if ok && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	data := json.RawMessage{}
+	resp, err := s.client.Do(ctx, req, &data)
+	if ok && resp != nil && resp.StatusCode == http.StatusNotModified {
+		return json.Unmarshal(cached.Data, v)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cache.store(key, &cacheEntry{ETag: resp.Header.Get("ETag"), Data: data}); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadCached reads an immutable object, such as the commits between two SHAs,
+// from the on-disk cache into v. It reports whether the object was cached.
+func (s *GitHubSource) loadCached(key string, v interface{}) bool {
+	if s.cacheDir == "" {
+		return false
+	}
+	cached, ok := diskCache(s.cacheDir).load(s.cacheKey(key))
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(cached.Data, v) == nil
+}
+
+// storeCached writes an immutable object to the on-disk cache, if it is enabled.
+func (s *GitHubSource) storeCached(key string, v interface{}) error {
+	if s.cacheDir == "" {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return diskCache(s.cacheDir).store(s.cacheKey(key), &cacheEntry{Data: data})
+}
+
+// cacheKey qualifies the key of an object with the host of the API it comes
+// from, so that the objects of a GitHub Enterprise instance and of github.com,
+// whose orgs and repositories may have the same names, are cached apart. The
+// port of the host, if any, is kept without the colon, which isn't allowed in
+// file names everywhere.
+func (s *GitHubSource) cacheKey(key string) string {
+	host := strings.ReplaceAll(s.client.BaseURL.Host, ":", "_")
+	return host + "/" + key
+}
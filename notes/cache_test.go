@@ -0,0 +1,78 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCachedRevalidates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "release-notes-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "/repos/netdata/netdata/pulls/42", r.URL.Path)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"number": 42, "title": "Add a feature"}`)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	source := NewGitHubSource(client, dir)
+
+	for i := 0; i < 2; i++ {
+		pr := &github.PullRequest{}
+		require.NoError(t, source.getCached(context.Background(), "netdata/netdata/pulls/42", "repos/netdata/netdata/pulls/42", pr))
+		require.Equal(t, 42, pr.GetNumber())
+		require.Equal(t, "Add a feature", pr.GetTitle())
+	}
+	require.Equal(t, 2, requests)
+
+	cached, ok := diskCache(dir).load(source.cacheKey("netdata/netdata/pulls/42"))
+	require.True(t, ok)
+	require.Equal(t, `"v1"`, cached.ETag)
+}
+
+func TestImmutableCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "release-notes-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := "netdata/netdata/commits/abc..def"
+	commits := []*github.RepositoryCommit{}
+
+	// nothing is cached when the cache is disabled
+	disabled := NewGitHubSource(github.NewClient(nil), "")
+	require.NoError(t, disabled.storeCached(key, []*github.RepositoryCommit{{SHA: github.String("def")}}))
+	require.False(t, disabled.loadCached(key, &commits))
+
+	source := NewGitHubSource(github.NewClient(nil), dir)
+	require.False(t, source.loadCached(key, &commits))
+	require.NoError(t, source.storeCached(key, []*github.RepositoryCommit{{SHA: github.String("def")}}))
+	require.True(t, source.loadCached(key, &commits))
+	require.Len(t, commits, 1)
+	require.Equal(t, "def", commits[0].GetSHA())
+
+	// the same repository of a GitHub Enterprise instance is cached apart
+	enterprise, err := github.NewEnterpriseClient("https://github.example.com/api/v3/", "", nil)
+	require.NoError(t, err)
+	require.False(t, NewGitHubSource(enterprise, dir).loadCached(key, &commits))
+	require.Equal(t, "api.github.com/"+key, source.cacheKey(key))
+	require.Equal(t, "github.example.com/"+key, NewGitHubSource(enterprise, dir).cacheKey(key))
+}
@@ -0,0 +1,207 @@
+package notes
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ChangelogHeader is the beginning of a new Keep a Changelog file, which
+// InsertChangelog starts from when there is no changelog yet.
+const ChangelogHeader = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.0.0/).
+`
+
+// unreleased is the version of the changes that haven't been released yet.
+const unreleased = "Unreleased"
+
+// The headings of a Keep a Changelog release, in order.
+const (
+	changelogAdded      = "Added"
+	changelogChanged    = "Changed"
+	changelogDeprecated = "Deprecated"
+	changelogRemoved    = "Removed"
+	changelogFixed      = "Fixed"
+	changelogSecurity   = "Security"
+)
+
+var changelogHeadings = []string{
+	changelogAdded,
+	changelogChanged,
+	changelogDeprecated,
+	changelogRemoved,
+	changelogFixed,
+	changelogSecurity,
+}
+
+var (
+	// changelogReleaseExp matches the heading of a release in a changelog, such
+	// as "## [1.0.0] - 2020-03-13"
+	changelogReleaseExp = regexp.MustCompile(`^## \[([^\]]+)\]`)
+
+	// changelogLinkExp matches the link reference definitions that conclude a
+	// changelog, such as "[1.0.0]: https://github.com/..."
+	changelogLinkExp = regexp.MustCompile(`^\[[^\]]+\]:\s`)
+)
+
+// RenderChangelog accepts a Document and writes it to the supplied io.Writer as
+// the section of a Keep a Changelog (https://keepachangelog.com) file for the
+// given version, released on the given date. The Unreleased version has no
+// date.
+//
+// The notes of every section are listed under the changelog heading of the
+// section, which is Changed unless the section is configured otherwise, except
+// for notes of the deprecation, removal and security kinds, which are listed
+// under Deprecated, Removed and Security. A note that is in several sections is
+// listed once, with the first of them.
+func RenderChangelog(doc *Document, version string, date time.Time, w io.Writer) error {
+	headings := map[string][]string{}
+	seen := map[*ReleaseNote]struct{}{}
+	add := func(heading string, notes []*ReleaseNote) {
+		if heading == "" {
+			heading = changelogChanged
+		}
+		for _, note := range notes {
+			// notes can be in more than one section of the document
+			if _, ok := seen[note]; ok {
+				continue
+			}
+			seen[note] = struct{}{}
+
+			text := strings.TrimPrefix(note.Markdown, "- ")
+			if note.ActionRequired {
+				text = "**Action required:** " + text
+			}
+			h := changelogHeading(note, heading)
+			headings[h] = append(headings[h], "- "+text)
+		}
+	}
+
+	for _, section := range doc.Sections {
+		add(section.Changelog, section.AllNotes())
+	}
+
+	b := &strings.Builder{}
+	if strings.EqualFold(version, unreleased) {
+		fmt.Fprintf(b, "## [%s]\n", unreleased)
+	} else {
+		fmt.Fprintf(b, "## [%s] - %s\n", version, date.Format("2006-01-02"))
+	}
+	for _, heading := range changelogHeadings {
+		if len(headings[heading]) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n### %s\n\n", heading)
+		for _, note := range headings[heading] {
+			b.WriteString(note + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// changelogHeading returns the heading that a note from a document section
+// that maps to the given heading goes under.
+func changelogHeading(note *ReleaseNote, heading string) string {
+	switch {
+	case HasString(note.Kinds, "security"):
+		return changelogSecurity
+	case HasString(note.Kinds, "removal"):
+		return changelogRemoved
+	case HasString(note.Kinds, "deprecation"):
+		return changelogDeprecated
+	}
+	return heading
+}
+
+// InsertChangelog inserts the section of a release, as rendered by
+// RenderChangelog, into a changelog and returns the updated changelog. If the
+// changelog already has a section for the release, that section is replaced,
+// wherever it is, so that the notes of a release can be regenerated. Otherwise,
+// the section is inserted in version order, which is above the latest release
+// and below the Unreleased section, if there is one, for a new release. The
+// other releases are left untouched. An empty changelog is started from
+// ChangelogHeader.
+func InsertChangelog(changelog, section string) string {
+	if strings.TrimSpace(changelog) == "" {
+		changelog = ChangelogHeader
+	}
+	section = strings.TrimRight(section, "\n") + "\n"
+	version := changelogVersion(section)
+
+	lines := strings.SplitAfter(changelog, "\n")
+	start, end := -1, 0
+	for i, line := range lines {
+		if v := changelogVersion(line); v != "" && sameVersion(v, version) {
+			start = i
+			break
+		}
+	}
+	if start >= 0 {
+		// the replaced section ends at the next release, or at the link
+		// references at the end of the changelog
+		end = len(lines)
+		for i := start + 1; i < len(lines); i++ {
+			if changelogVersion(lines[i]) != "" || changelogLinkExp.MatchString(lines[i]) {
+				end = i
+				break
+			}
+		}
+	} else {
+		// the section goes right above the first release that is older, which
+		// is the latest release unless an older one is being added, or at the
+		// end if there are no releases yet
+		start = len(lines)
+		for i, line := range lines {
+			v := changelogVersion(line)
+			if changelogLinkExp.MatchString(line) || (v != "" && !strings.EqualFold(v, unreleased) && !newerVersion(v, version)) {
+				start = i
+				break
+			}
+		}
+		end = start
+	}
+
+	before := strings.Join(lines[:start], "")
+	after := strings.Join(lines[end:], "")
+	if before != "" && !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	if before != "" && !strings.HasSuffix(before, "\n\n") {
+		before += "\n"
+	}
+	if after != "" {
+		section += "\n"
+	}
+	return before + section + strings.TrimLeft(after, "\n")
+}
+
+// changelogVersion returns the version of the release whose heading starts s,
+// or nothing if s doesn't start with the heading of a release.
+func changelogVersion(s string) string {
+	match := changelogReleaseExp.FindStringSubmatch(s)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// sameVersion reports whether two changelog versions are the same, regardless
+// of whether they are prefixed with a v, as tags often are.
+func sameVersion(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v"))
+}
+
+// newerVersion reports whether the changelog version a is newer than b. Versions
+// that aren't semantic versions aren't ordered, so neither is newer.
+func newerVersion(a, b string) bool {
+	va, aOk := parseSemver(a)
+	vb, bOk := parseSemver(b)
+	return aOk && bOk && vb.less(va)
+}
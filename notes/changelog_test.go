@@ -0,0 +1,84 @@
+package notes
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderChangelogUnreleased(t *testing.T) {
+	doc, err := CreateDocument([]*ReleaseNote{{Markdown: "Fix the disk space alarm thresholds", Kinds: []string{"bug"}}})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderChangelog(doc, "unreleased", time.Now(), buf))
+	require.Equal(t, "## [Unreleased]\n\n### Fixed\n\n- Fix the disk space alarm thresholds\n", buf.String())
+}
+
+func TestInsertChangelog(t *testing.T) {
+	section := "## [1.1.0] - 2020-03-13\n\n### Added\n\n- New\n"
+	header := "# Changelog\n\nNotable changes.\n"
+	older := "## [1.0.0] - 2020-02-01\n\n### Fixed\n\n- Old\n"
+	unreleased := "## [Unreleased]\n\n### Added\n\n- Upcoming\n"
+	patch := "## [1.0.1] - 2020-02-15\n\n### Fixed\n\n- Backported\n"
+	links := "[1.0.0]: https://github.com/netdata/netdata/releases/tag/v1.0.0\n"
+
+	cases := map[string]struct {
+		changelog string
+		section   string
+		expected  string
+	}{
+		"new changelog": {
+			changelog: "",
+			expected:  ChangelogHeader + "\n" + section,
+		},
+		"no releases yet": {
+			changelog: header,
+			expected:  header + "\n" + section,
+		},
+		"above the latest release": {
+			changelog: header + "\n" + older,
+			expected:  header + "\n" + section + "\n" + older,
+		},
+		"below the unreleased changes": {
+			changelog: header + "\n" + unreleased + "\n" + older,
+			expected:  header + "\n" + unreleased + "\n" + section + "\n" + older,
+		},
+		"above the link references": {
+			changelog: header + "\n" + links,
+			expected:  header + "\n" + section + "\n" + links,
+		},
+		"replaces the same release": {
+			changelog: header + "\n## [v1.1.0] - 2020-03-12\n\n### Added\n\n- Stale\n\n" + older + "\n" + links,
+			expected:  header + "\n" + section + "\n" + older + "\n" + links,
+		},
+		"replaces the last release": {
+			changelog: header + "\n## [1.1.0] - 2020-03-12\n\n- Stale\n\n" + links,
+			expected:  header + "\n" + section + "\n" + links,
+		},
+		"replaces an older release": {
+			changelog: header + "\n" + section + "\n## [1.0.0] - 2020-01-31\n\n- Stale\n\n" + links,
+			section:   older,
+			expected:  header + "\n" + section + "\n" + older + "\n" + links,
+		},
+		"in version order": {
+			changelog: header + "\n" + unreleased + "\n" + section + "\n" + older + "\n" + links,
+			section:   patch,
+			expected:  header + "\n" + unreleased + "\n" + section + "\n" + patch + "\n" + older + "\n" + links,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if c.section == "" {
+				c.section = section
+			}
+			require.Equal(t, c.expected, InsertChangelog(c.changelog, c.section))
+
+			// inserting the same section again changes nothing
+			require.Equal(t, c.expected, InsertChangelog(c.expected, c.section))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package notes
+
+import (
+	"sync"
+)
+
+// forEach calls fn once for every index in [0, n) from a pool of at most
+// concurrency workers, and returns when all of the calls have completed. Callers
+// that need deterministic output should store results by index rather than in
+// the order the calls complete.
+func forEach(concurrency, n int, fn func(i int)) {
+	if concurrency > n {
+		concurrency = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// memo remembers the results of API requests made while generating a set of
+// release notes, so that every PR and issue is only fetched once, even when
+// several workers ask for it at the same time.
+type memo struct {
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+}
+
+// memoEntry is the result of a single memoized API request.
+type memoEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func newMemo() *memo {
+	return &memo{entries: map[string]*memoEntry{}}
+}
+
+// do returns the memoized result for key, calling fn to produce it if this is
+// the first time key has been requested. Concurrent callers asking for the same
+// key wait for the first call to fn to complete.
+func (m *memo) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &memoEntry{}
+		m.entries[key] = entry
+	}
+	m.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = fn()
+	})
+	return entry.value, entry.err
+}
@@ -0,0 +1,58 @@
+package notes
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEach(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+	results := make([]int, 100)
+
+	forEach(4, len(results), func(i int) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		results[i] = i * i
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+
+	require.True(t, peak <= 4, "at most 4 workers may run at once, got %d", peak)
+	for i, result := range results {
+		require.Equal(t, i*i, result)
+	}
+}
+
+func TestMemo(t *testing.T) {
+	m := newMemo()
+	var calls int32
+	values := make([]interface{}, 32)
+	errs := make([]error, 32)
+
+	forEach(8, len(values), func(i int) {
+		values[i], errs[i] = m.do("netdata/netdata/pulls/1", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return "the PR", nil
+		})
+	})
+
+	require.EqualValues(t, 1, calls)
+	for i := range values {
+		require.NoError(t, errs[i])
+		require.Equal(t, "the PR", values[i])
+	}
+}
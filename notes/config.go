@@ -0,0 +1,429 @@
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Config declares how release notes are categorized into the sections of a
+// document. It is usually loaded from a JSON file with LoadConfig, and defaults
+// to DefaultConfig.
+type Config struct {
+	// SkipLabels are the labels that leave a PR out of the release notes
+	// when they are set on the PR or on the issue it closes.
+	SkipLabels []string `json:"skip_labels"`
+
+	// IssueKinds maps the labels of the issues that a PR closes to the kinds
+	// of its release note, such as "feature request" to "feature", on top of
+	// the kind/ labels of the PR, or of the issues if the PR has none.
+	IssueKinds map[string]string `json:"issue_kinds"`
+
+	// ExcludeAuthors are the logins of the authors, such as bots, whose PRs
+	// are left out of the release notes, unless there is a Bots section to
+	// collect them in. A * in a login matches any characters, so "*[bot]"
+	// matches all of the GitHub Apps. Logins are matched case insensitively.
+	ExcludeAuthors []string `json:"exclude_authors"`
+
+	// Sections are the sections of the document, in the order they are
+	// rendered in.
+	Sections []*SectionConfig `json:"sections"`
+}
+
+// SectionConfig declares a section of a document, and which notes go in it.
+//
+// A section matches a note if the note has one of its Labels, a label starting
+// with one of its LabelPrefixes, or if one of the issues that the PR closes has
+// one of its IssueLabels. Sections that group their notes also require the note
+// to have at least MinGroups labels starting with GroupBy. A section without
+// any of these rules matches every note.
+//
+// The kind/, area/ and sig/ labels of a note are those of its Kinds, Areas and
+// SIGs, so they come from the issues that the PR closes when the PR has none,
+// and the kinds that IssueKinds maps the labels of those issues to are
+// included. The release-note-action-required label matches the notes that
+// require action, even when the PR only has a block of that kind.
+//
+// Every note goes in the first section that matches it, trying the sections in
+// the order of their Precedence, and then in the order they are declared in.
+// If that section has Continue set, the note may go in the next matching
+// sections too, except for Fallback sections, which only take the notes that
+// haven't gone in any other section.
+type SectionConfig struct {
+	// ID identifies the section in the JSON representation of documents.
+	ID string `json:"id"`
+
+	// Title is the heading of the section.
+	Title string `json:"title"`
+
+	// Labels are the labels of the notes that go in the section.
+	Labels []string `json:"labels,omitempty"`
+
+	// LabelPrefixes are prefixes, such as "area/", of the labels of the notes
+	// that go in the section.
+	LabelPrefixes []string `json:"label_prefixes,omitempty"`
+
+	// IssueLabels are the labels of the issues closed by the PRs of the notes
+	// that go in the section.
+	IssueLabels []string `json:"issue_labels,omitempty"`
+
+	// GroupBy is a label prefix, such as "sig/", that the notes in the section
+	// are grouped by. The groups are titled by the labels without the prefix,
+	// prettified and formatted with GroupTitle, such as "SIG %s".
+	GroupBy    string `json:"group_by,omitempty"`
+	GroupTitle string `json:"group_title,omitempty"`
+
+	// MinGroups is the number of GroupBy labels that a note needs to go in the
+	// section, which is at least 1.
+	MinGroups int `json:"min_groups,omitempty"`
+
+	// CombineGroups puts each note in a single group for the combination of
+	// its GroupBy labels, rather than in one group per label.
+	CombineGroups bool `json:"combine_groups,omitempty"`
+
+	// Precedence decides which sections are matched against the notes first,
+	// lowest first.
+	Precedence int `json:"precedence,omitempty"`
+
+	// Continue keeps matching a note against the next sections after it went
+	// in this one.
+	Continue bool `json:"continue,omitempty"`
+
+	// Fallback only matches notes that haven't gone in any other section.
+	Fallback bool `json:"fallback,omitempty"`
+
+	// Changelog is the Keep a Changelog heading of the notes in the section,
+	// which defaults to Changed.
+	Changelog string `json:"changelog,omitempty"`
+
+	// Bots makes the section take the notes of the ExcludeAuthors, which don't
+	// go in any other section, rather than leaving them out.
+	Bots bool `json:"bots,omitempty"`
+
+	// Collapsed renders the notes of the section collapsed, so that they don't
+	// take up much room.
+	Collapsed bool `json:"collapsed,omitempty"`
+}
+
+// DefaultConfig returns the configuration that documents are created with
+// unless another one is given with WithConfig.
+func DefaultConfig() *Config {
+	return &Config{
+		SkipLabels: []string{"no changelog"},
+		IssueKinds: map[string]string{
+			"feature request": "feature",
+			"enhancement":     "feature",
+			"bug":             "bug",
+		},
+		ExcludeAuthors: []string{"netdatabot", "*[bot]", "dependabot*", "renovate*"},
+		Sections: []*SectionConfig{
+			{
+				ID:         "action_required",
+				Title:      "Action Required",
+				Labels:     []string{"release-note-action-required"},
+				Precedence: 0,
+			},
+			{
+				ID:         "new_features",
+				Title:      "New Features",
+				Labels:     []string{"kind/feature"},
+				Precedence: 1,
+				Changelog:  changelogAdded,
+			},
+			{
+				// the documentation changes have always been under
+				// api_changes in the JSON of documents
+				ID:         "api_changes",
+				Title:      "Documentation",
+				Labels:     []string{"area/docs"},
+				Precedence: 3,
+				Continue:   true,
+			},
+			{
+				ID:         "packaging_changes",
+				Title:      "Packaging / Installation",
+				Labels:     []string{"area/packaging"},
+				Precedence: 3,
+				Continue:   true,
+			},
+			{
+				ID:            "duplicate_notes",
+				Title:         "Notes From Multiple SIGs",
+				GroupBy:       "sig/",
+				GroupTitle:    "SIG %s",
+				MinGroups:     2,
+				CombineGroups: true,
+				Precedence:    2,
+			},
+			{
+				ID:         "sigs",
+				Title:      "Notes from Individual SIGs",
+				GroupBy:    "sig/",
+				GroupTitle: "SIG %s",
+				Precedence: 3,
+				Continue:   true,
+			},
+			{
+				ID:         "bug_fixes",
+				Title:      "Bug Fixes",
+				Labels:     []string{"kind/bug"},
+				Precedence: 4,
+				Fallback:   true,
+				Changelog:  changelogFixed,
+			},
+			{
+				ID:         "uncategorized",
+				Title:      "Other Notable Changes",
+				Precedence: 5,
+				Fallback:   true,
+			},
+		},
+	}
+}
+
+// DependencyUpdatesSection returns a collapsed section that collects the notes
+// of the ExcludeAuthors, such as the dependency updates opened by bots.
+func DependencyUpdatesSection() *SectionConfig {
+	return &SectionConfig{
+		ID:        "dependency_updates",
+		Title:     "Dependency Updates",
+		Bots:      true,
+		Collapsed: true,
+	}
+}
+
+// LoadConfig reads a configuration from a JSON file. The keys that the file
+// leaves out keep the values of DefaultConfig, while the ones it sets replace
+// them, and unknown keys are an error so that typos don't go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the config")
+	}
+	defer f.Close()
+
+	c := DefaultConfig()
+
+	// maps, and the structs that slices point to, are merged into rather than
+	// replaced when decoding
+	issueKinds, sections := c.IssueKinds, c.Sections
+	c.IssueKinds, c.Sections = nil, nil
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, errors.Wrapf(err, "error parsing the config %s", path)
+	}
+	if c.IssueKinds == nil {
+		c.IssueKinds = issueKinds
+	}
+	if c.Sections == nil {
+		c.Sections = sections
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid config %s", path)
+	}
+	return c, nil
+}
+
+// Validate checks that the sections of a configuration are well-formed.
+func (c *Config) Validate() error {
+	if len(c.Sections) == 0 {
+		return errors.New("no sections are configured")
+	}
+	ids := map[string]struct{}{}
+	for i, s := range c.Sections {
+		if s.ID == "" {
+			return fmt.Errorf("section %d has no id", i+1)
+		}
+		if _, ok := ids[s.ID]; ok {
+			return fmt.Errorf("section %s is configured more than once", s.ID)
+		}
+		ids[s.ID] = struct{}{}
+		if s.Title == "" {
+			return fmt.Errorf("section %s has no title", s.ID)
+		}
+		if s.GroupBy == "" && (s.GroupTitle != "" || s.MinGroups != 0 || s.CombineGroups) {
+			return fmt.Errorf("section %s configures groups without group_by", s.ID)
+		}
+		if s.Bots && s.Fallback {
+			return fmt.Errorf("section %s can't be both a bots and a fallback section", s.ID)
+		}
+		if s.Changelog != "" && !HasString(changelogHeadings, s.Changelog) {
+			return fmt.Errorf("section %s has the changelog heading %q, must be one of %s", s.ID, s.Changelog, strings.Join(changelogHeadings, ", "))
+		}
+	}
+	return nil
+}
+
+// excludesAuthor reports whether the PRs of an author are left out of the
+// release notes, or collected in a Bots section.
+func (c *Config) excludesAuthor(login string) bool {
+	if login == "" {
+		return false
+	}
+	for _, pattern := range c.ExcludeAuthors {
+		if authorPattern(pattern).MatchString(login) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectsBots reports whether there is a Bots section to collect the notes of
+// the ExcludeAuthors in.
+func (c *Config) collectsBots() bool {
+	for _, s := range c.Sections {
+		if s.Bots {
+			return true
+		}
+	}
+	return false
+}
+
+// authorPattern turns a login pattern of ExcludeAuthors into a regular
+// expression. Only * is special, so that the brackets of "[bot]" are literal.
+func authorPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$")
+}
+
+// issueKinds returns the kinds that the labels of an issue map to.
+func (c *Config) issueKinds(labels []string) []string {
+	kinds := []string{}
+	for _, label := range labels {
+		if kind, ok := c.IssueKinds[label]; ok && !HasString(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// precedence returns the sections in the order they are matched against notes.
+func (c *Config) precedence() []*SectionConfig {
+	sections := append([]*SectionConfig{}, c.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Precedence < sections[j].Precedence
+	})
+	return sections
+}
+
+// matches reports whether a note goes in the section.
+func (s *SectionConfig) matches(note *ReleaseNote) bool {
+	// the notes of bots only go in the sections that collect them
+	if s.Bots != note.Bot {
+		return false
+	}
+	if s.GroupBy != "" {
+		min := s.MinGroups
+		if min < 1 {
+			min = 1
+		}
+		if len(note.labelsWithPrefix(s.GroupBy)) < min {
+			return false
+		}
+	}
+	if len(s.Labels) == 0 && len(s.LabelPrefixes) == 0 && len(s.IssueLabels) == 0 {
+		return true
+	}
+	for _, label := range s.Labels {
+		if note.hasLabel(label) {
+			return true
+		}
+	}
+	for _, prefix := range s.LabelPrefixes {
+		if len(note.labelsWithPrefix(prefix)) > 0 {
+			return true
+		}
+	}
+	for _, label := range s.IssueLabels {
+		if HasString(note.IssueLabels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// groups returns the keys and the titles of the groups that a note goes in.
+func (s *SectionConfig) groups(note *ReleaseNote) (keys, titles []string) {
+	// the labels of the note are sorted in place, so that they are listed in
+	// the same order as in the titles of its groups
+	labels := note.labelsWithPrefix(s.GroupBy)
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		title := prettySIG(label)
+		if s.GroupTitle != "" {
+			title = fmt.Sprintf(s.GroupTitle, title)
+		}
+		keys = append(keys, label)
+		titles = append(titles, title)
+	}
+	if !s.CombineGroups {
+		return keys, titles
+	}
+
+	// combined groups are keyed by their title, which lists all of the labels
+	title := ""
+	for i, t := range titles {
+		switch {
+		case i == 0:
+			title = t
+		case i == len(titles)-1:
+			title = fmt.Sprintf("%s, and %s", title, t)
+		default:
+			title = fmt.Sprintf("%s, %s", title, t)
+		}
+	}
+	return []string{title}, []string{title}
+}
+
+// labelsWithPrefix returns the labels of a note that start with a prefix,
+// without it. The kind/, area/ and sig/ labels are those of its Kinds, Areas
+// and SIGs, and the others are those of its PR.
+func (n *ReleaseNote) labelsWithPrefix(prefix string) []string {
+	switch prefix {
+	case "kind/":
+		return n.Kinds
+	case "area/":
+		return n.Areas
+	case "sig/":
+		return n.SIGs
+	}
+	return StringsWithPrefix(n.Labels, prefix)
+}
+
+// hasLabel reports whether a note has a label, as matched by the Labels of a
+// section.
+func (n *ReleaseNote) hasLabel(label string) bool {
+	switch {
+	case label == BlockActionRequired:
+		return n.ActionRequired
+	case label == "kind/feature" && n.Feature:
+		return true
+	}
+	for _, prefix := range []string{"kind/", "area/", "sig/"} {
+		if strings.HasPrefix(label, prefix) {
+			return HasString(n.labelsWithPrefix(prefix), strings.TrimPrefix(label, prefix))
+		}
+	}
+	return HasString(n.Labels, label)
+}
+
+// firstOf returns the first of xs that is in ys, or nothing if none is.
+func firstOf(xs, ys []string) string {
+	for _, x := range xs {
+		if HasString(ys, x) {
+			return x
+		}
+	}
+	return ""
+}
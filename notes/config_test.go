@@ -0,0 +1,182 @@
+package notes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "release-notes-config")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+  "skip_labels": ["skip-release-notes"],
+  "sections": [
+    {"id": "breaking", "title": "Breaking Changes", "labels": ["breaking"]},
+    {"id": "other", "title": "Changes", "fallback": true, "precedence": 1}
+  ]
+}`), 0644))
+
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"skip-release-notes"}, c.SkipLabels)
+	require.Len(t, c.Sections, 2)
+	require.Equal(t, "breaking", c.Sections[0].ID)
+	require.Equal(t, &SectionConfig{ID: "other", Title: "Changes", Fallback: true, Precedence: 1}, c.Sections[1])
+
+	// the keys that are left out keep their defaults
+	require.Equal(t, DefaultConfig().ExcludeAuthors, c.ExcludeAuthors)
+	require.Equal(t, DefaultConfig().IssueKinds, c.IssueKinds)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"issue_kinds": {"bug": "bug"}}`), 0644))
+	c, err = LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"bug": "bug"}, c.IssueKinds)
+	require.Equal(t, DefaultConfig().Sections, c.Sections)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"skip_label": ["skip-release-notes"]}`), 0644))
+	_, err = LoadConfig(path)
+	require.Error(t, err)
+
+	_, err = LoadConfig(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"sections": [`), 0644))
+	_, err = LoadConfig(path)
+	require.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := map[string][]*SectionConfig{
+		"no sections":   nil,
+		"no id":         {{Title: "Changes"}},
+		"no title":      {{ID: "changes"}},
+		"duplicate id":  {{ID: "changes", Title: "Changes"}, {ID: "changes", Title: "More Changes"}},
+		"no group_by":   {{ID: "changes", Title: "Changes", MinGroups: 2}},
+		"bad changelog": {{ID: "changes", Title: "Changes", Changelog: "Misc"}},
+	}
+	for name, sections := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, (&Config{Sections: sections}).Validate())
+		})
+	}
+}
+
+func TestConfigExcludesAuthor(t *testing.T) {
+	c := DefaultConfig()
+	for login, excluded := range map[string]bool{
+		"netdatabot":              true,
+		"NetdataBot":              true,
+		"dependabot[bot]":         true,
+		"dependabot-preview[bot]": true,
+		"renovate-bot":            true,
+		"github-actions[bot]":     true,
+		"alice":                   false,
+		"netdatabot2":             false,
+		"bot":                     false,
+		"robot":                   false,
+		"":                        false,
+	} {
+		require.Equal(t, excluded, c.excludesAuthor(login), login)
+	}
+
+	// brackets are literal rather than a character class
+	c.ExcludeAuthors = []string{"[bot]"}
+	require.True(t, c.excludesAuthor("[bot]"))
+	require.False(t, c.excludesAuthor("b"))
+}
+
+func TestCreateDocumentWithConfig(t *testing.T) {
+	config := &Config{
+		Sections: []*SectionConfig{
+			{ID: "fixes", Title: "Fixes", Labels: []string{"type/fix"}, Precedence: 1, Changelog: changelogFixed},
+			{ID: "breaking", Title: "Breaking Changes", Labels: []string{"breaking"}, Continue: true},
+			{ID: "teams", Title: "Teams", GroupBy: "team/", GroupTitle: "Team %s", Precedence: 1, Continue: true},
+			{ID: "other", Title: "Other Changes", Precedence: 2, Fallback: true},
+		},
+	}
+
+	breaking := &ReleaseNote{Text: "breaking", Labels: []string{"breaking", "type/fix"}}
+	fix := &ReleaseNote{Text: "fix", Labels: []string{"type/fix", "team/web"}}
+	team := &ReleaseNote{Text: "team", Labels: []string{"team/web", "team/agent"}}
+	other := &ReleaseNote{Text: "other", Labels: []string{"kind/feature"}}
+
+	doc, err := CreateDocument([]*ReleaseNote{breaking, fix, team, other}, WithConfig(config))
+	require.NoError(t, err)
+
+	// the sections are in the order they are configured in, and matched in the
+	// order of their precedence
+	require.Len(t, doc.Sections, 4)
+	require.Equal(t, "fixes", doc.Sections[0].ID)
+	require.Equal(t, []*ReleaseNote{breaking}, doc.Section("breaking").Notes)
+	require.Equal(t, []*ReleaseNote{breaking, fix}, doc.Section("fixes").Notes)
+	require.Equal(t, []*ReleaseNote{other}, doc.Section("other").Notes)
+
+	teams := doc.Section("teams")
+	require.True(t, teams.Grouped)
+	require.Len(t, teams.Groups, 2)
+	require.Equal(t, "Team Agent", teams.Groups[0].Title)
+	require.Equal(t, []*ReleaseNote{team}, teams.Groups[0].Notes)
+	require.Equal(t, "Team Web", teams.Groups[1].Title)
+	require.Equal(t, []*ReleaseNote{team}, teams.Groups[1].Notes)
+	require.Nil(t, doc.Section("missing"))
+
+	// fixes stops matching, so the fix isn't in its team section
+	require.NotContains(t, teams.AllNotes(), fix)
+}
+
+func TestConfigMatchers(t *testing.T) {
+	config, err := LoadConfig(filepath.Join("testdata", "config", "matchers.json"))
+	require.NoError(t, err)
+
+	security := &ReleaseNote{Text: "security", Areas: []string{"web"}, IssueLabels: []string{"bug", "security"}}
+	area := &ReleaseNote{Text: "area", Areas: []string{"web"}, IssueLabels: []string{"bug"}}
+	component := &ReleaseNote{Text: "component", Labels: []string{"component/agent"}}
+	other := &ReleaseNote{Text: "other", Labels: []string{"security", "areas/web"}}
+
+	doc, err := CreateDocument([]*ReleaseNote{security, area, component, other}, WithConfig(config))
+	require.NoError(t, err)
+
+	// the labels of the PR don't match issue_labels, and the labels of the
+	// issues are only matched by them
+	require.Equal(t, []*ReleaseNote{security}, doc.Section("security").Notes)
+	require.Equal(t, []*ReleaseNote{area, component}, doc.Section("areas").Notes)
+	require.Equal(t, []*ReleaseNote{other}, doc.Section("other").Notes)
+}
+
+func TestDefaultConfigMatchesNoteFields(t *testing.T) {
+	// the notes only have the fields that ReleaseNoteFromCommit derives from
+	// the labels of the PR and of its issues, as in the JSON of older releases
+	actionRequired := &ReleaseNote{Text: "action", ActionRequired: true, Kinds: []string{"feature"}, Feature: true}
+	feature := &ReleaseNote{Text: "feature", Kinds: []string{"feature"}, Feature: true}
+	docs := &ReleaseNote{Text: "docs", Kinds: []string{"bug"}, Areas: []string{"docs"}}
+	sigs := &ReleaseNote{Text: "sigs", SIGs: []string{"web", "health"}, Duplicate: true}
+	bug := &ReleaseNote{Text: "bug", Kinds: []string{"bug"}, SIGs: []string{"web"}}
+	other := &ReleaseNote{Text: "other", Labels: []string{"kind/bug", "area/docs"}}
+
+	doc, err := CreateDocument([]*ReleaseNote{actionRequired, feature, docs, sigs, bug, other})
+	require.NoError(t, err)
+	require.Equal(t, []*ReleaseNote{actionRequired}, doc.Section("action_required").Notes)
+	require.Equal(t, []*ReleaseNote{feature}, doc.Section("new_features").Notes)
+	require.Equal(t, []*ReleaseNote{docs}, doc.Section("api_changes").Notes)
+	require.Len(t, doc.Section("duplicate_notes").Groups, 1)
+	require.Equal(t, "SIG Health, and SIG Web", doc.Section("duplicate_notes").Groups[0].Title)
+	require.Equal(t, []*ReleaseNote{sigs}, doc.Section("duplicate_notes").Groups[0].Notes)
+	require.Len(t, doc.Section("sigs").Groups, 1)
+	require.Equal(t, []*ReleaseNote{bug}, doc.Section("sigs").Groups[0].Notes)
+
+	// the kind/ and area/ labels of a note are its Kinds and Areas, whatever
+	// labels its PR had
+	require.Empty(t, doc.Section("bug_fixes").Notes)
+	require.Equal(t, []*ReleaseNote{other}, doc.Section("uncategorized").Notes)
+}
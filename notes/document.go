@@ -1,221 +1,251 @@
 package notes
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
 )
 
-// Document represents the underlying structure of a release notes document.
+// Document represents the underlying structure of a release notes document,
+// which is made of the sections declared by a Config.
 type Document struct {
-	NewFeatures      []string `json:"new_features"`
-	ActionRequired   []string `json:"action_required"`
-	DocChanges       []string `json:"api_changes"`
-	PackagingChanges []string
-	Duplicates       map[string][]string `json:"duplicate_notes"`
-	SIGs             map[string][]string `json:"sigs"`
-	BugFixes         []string            `json:"bug_fixes"`
-	Uncategorized    []string            `json:"uncategorized"`
+	Sections []*Section
+}
+
+// Section is a section of a document. The notes in a section are either listed
+// directly, or in groups, if the section is configured with GroupBy.
+type Section struct {
+	// ID identifies the section in the JSON representation of the document.
+	ID string
+
+	// Title is the heading of the section.
+	Title string
+
+	// Changelog is the Keep a Changelog heading of the notes in the section.
+	Changelog string
+
+	// Grouped is set if the notes of the section are in Groups rather than in
+	// Notes.
+	Grouped bool
+
+	// Combined is set if each note of a grouped section is in a single group.
+	Combined bool
+
+	// Collapsed is set if the notes of the section are rendered collapsed.
+	Collapsed bool
+
+	Notes  []*ReleaseNote
+	Groups []*Group
+}
+
+// Group is a group of notes in a section, such as the notes of a SIG.
+type Group struct {
+	// Key identifies the group in the JSON representation of the document.
+	Key string
+
+	// Title is the heading of the group.
+	Title string
+
+	Notes []*ReleaseNote
 }
 
 // CreateDocument assembles an organized document from an unorganized set of
-// release notes
-func CreateDocument(notes []*ReleaseNote) (*Document, error) {
-	doc := &Document{
-		NewFeatures:      []string{},
-		ActionRequired:   []string{},
-		DocChanges:       []string{},
-		PackagingChanges: []string{},
-		Duplicates:       map[string][]string{},
-		SIGs:             map[string][]string{},
-		BugFixes:         []string{},
-		Uncategorized:    []string{},
+// release notes, with the sections declared by the configuration passed with
+// WithConfig, or DefaultConfig. The groups of a section are sorted by their
+// keys.
+func CreateDocument(notes []*ReleaseNote, opts ...githubApiOption) (*Document, error) {
+	c := configFromOpts(opts...)
+	if err := c.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	sections := map[*SectionConfig]*Section{}
+	groups := map[*Section]map[string]*Group{}
+	for _, sc := range c.config.Sections {
+		section := &Section{
+			ID:        sc.ID,
+			Title:     sc.Title,
+			Changelog: sc.Changelog,
+			Grouped:   sc.GroupBy != "",
+			Combined:  sc.CombineGroups,
+			Collapsed: sc.Collapsed,
+			Notes:     []*ReleaseNote{},
+			Groups:    []*Group{},
+		}
+		if section.Changelog == "" {
+			section.Changelog = changelogChanged
+		}
+		doc.Sections = append(doc.Sections, section)
+		sections[sc] = section
+		groups[section] = map[string]*Group{}
 	}
 
+	precedence := c.config.precedence()
 	for _, note := range notes {
 		categorized := false
-
-		if note.ActionRequired {
-			categorized = true
-			doc.ActionRequired = append(doc.ActionRequired, note.Markdown)
-		} else if note.Feature {
-			categorized = true
-			doc.NewFeatures = append(doc.NewFeatures, note.Markdown)
-		} else if note.Duplicate {
-			categorized = true
-			header := prettifySigList(note.SIGs)
-			existingNotes, ok := doc.Duplicates[header]
-			if ok {
-				doc.Duplicates[header] = append(existingNotes, note.Markdown)
-			} else {
-				doc.Duplicates[header] = []string{note.Markdown}
+		for _, sc := range precedence {
+			if sc.Fallback && categorized {
+				continue
 			}
-		} else {
-			for _, sig := range note.SIGs {
-				categorized = true
-				notesForSIG, ok := doc.SIGs[sig]
-				if ok {
-					doc.SIGs[sig] = append(notesForSIG, note.Markdown)
-				} else {
-					doc.SIGs[sig] = []string{note.Markdown}
-				}
-			}
-
-			for _, area := range note.Areas {
-				switch area {
-				case "docs":
-					categorized = true
-					doc.DocChanges = append(doc.DocChanges, note.Markdown)
-				case "packaging":
-					categorized = true
-					doc.PackagingChanges = append(doc.PackagingChanges, note.Markdown)
-				}
+			if !sc.matches(note) {
+				continue
 			}
+			categorized = true
 
-			isBug := false
-			for _, kind := range note.Kinds {
-				switch kind {
-				case "bug":
-					// if the PR has kind/bug, we want to make a note of it, but we don't
-					// include it in the Bug Fixes section until we haven't processed all
-					// kinds and determined that it has no other categorization label.
-					isBug = true
-				case "feature":
-					continue
+			section := sections[sc]
+			if !section.Grouped {
+				section.Notes = append(section.Notes, note)
+			} else {
+				keys, titles := sc.groups(note)
+				for i, key := range keys {
+					group, ok := groups[section][key]
+					if !ok {
+						group = &Group{Key: key, Title: titles[i]}
+						groups[section][key] = group
+						section.Groups = append(section.Groups, group)
+					}
+					group.Notes = append(group.Notes, note)
 				}
 			}
 
-			// if the note has not been categorized so far, we can toss in one of two
-			// buckets
-			if !categorized {
-				if isBug {
-					doc.BugFixes = append(doc.BugFixes, note.Markdown)
-				} else {
-					doc.Uncategorized = append(doc.Uncategorized, note.Markdown)
-				}
+			if !sc.Continue {
+				break
 			}
 		}
 	}
+
+	for _, section := range doc.Sections {
+		sort.Slice(section.Groups, func(i, j int) bool {
+			return section.Groups[i].Key < section.Groups[j].Key
+		})
+	}
 	return doc, nil
 }
 
-// RenderMarkdown accepts a Document and writes a version of that document to
-// supplied io.Writer in markdown format.
-func RenderMarkdown(doc *Document, w io.Writer) error {
-	// we always want to render the document with SIGs in alphabetical order
-	sortedSIGs := []string{}
-	for sig, _ := range doc.SIGs {
-		sortedSIGs = append(sortedSIGs, sig)
+// Section returns the section of the document with the given ID, or nil if
+// there is no such section.
+func (d *Document) Section(id string) *Section {
+	for _, section := range d.Sections {
+		if section.ID == id {
+			return section
+		}
 	}
-	sort.Strings(sortedSIGs)
-
-	// this is a helper so that we don't have to check err != nil on every write
+	return nil
+}
 
-	// first, we create a long-lived err that we can re-use
-	var err error
+// Empty reports whether there are no notes in the section.
+func (s *Section) Empty() bool {
+	return len(s.Notes) == 0 && len(s.Groups) == 0
+}
 
-	// write is a helper that writes a string to the in-scope io.Writer w
-	write := func(s string) {
-		// if write has already failed, just return and don't do anything
-		if err != nil {
-			return
+// AllNotes returns the notes in the section, including those in its groups. A
+// note that is in several groups is only returned once.
+func (s *Section) AllNotes() []*ReleaseNote {
+	notes := append([]*ReleaseNote{}, s.Notes...)
+	seen := map[*ReleaseNote]struct{}{}
+	for _, group := range s.Groups {
+		for _, note := range group.Notes {
+			if _, ok := seen[note]; !ok {
+				seen[note] = struct{}{}
+				notes = append(notes, note)
+			}
 		}
-		// perform the write
-		_, err = w.Write([]byte(s))
 	}
+	return notes
+}
 
-	// writeNote encapsulates the pre-processing that might happen on a note text
-	// before it gets bulleted and written to the io.Writer
-	writeNote := func(s string) {
-		if !strings.HasPrefix(s, "- ") {
-			s = "- " + s
-		}
-		write(s + "\n")
-	}
+// jsonKeys are the keys of the sections of DefaultConfig in the JSON
+// representation of documents, in the order they have always been in.
+var jsonKeys = []string{
+	"new_features",
+	"action_required",
+	"api_changes",
+	"packaging_changes",
+	"duplicate_notes",
+	"sigs",
+	"bug_fixes",
+	"uncategorized",
+}
 
-	// the "Action Required" section
-	if len(doc.ActionRequired) > 0 {
-		write("## Action Required\n\n")
-		for _, note := range doc.ActionRequired {
-			writeNote(note)
+// MarshalJSON implements json.Marshaler. A document is represented by an object
+// with a key for each section, which holds the list of notes in the section, or
+// an object with a key for each group if the section is grouped. Every section
+// is present, even when it is empty. The sections of DefaultConfig come first,
+// in the order of jsonKeys whatever order they are rendered in, followed by
+// the other sections in order.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	sections := []*Section{}
+	for _, key := range jsonKeys {
+		if section := d.Section(key); section != nil {
+			sections = append(sections, section)
 		}
-		write("\n\n")
 	}
-
-	// the "New Feautres" section
-	if len(doc.NewFeatures) > 0 {
-		write("## New Features\n\n")
-		for _, note := range doc.NewFeatures {
-			writeNote(note)
+	for _, section := range d.Sections {
+		if !HasString(jsonKeys, section.ID) {
+			sections = append(sections, section)
 		}
-		write("\n\n")
 	}
 
-	// the "Doc Changes" section
-	if len(doc.DocChanges) > 0 {
-		write("## Documentation\n\n")
-		for _, note := range doc.DocChanges {
-			writeNote(note)
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, section := range sections {
+		if i > 0 {
+			buf.WriteString(",")
 		}
-		write("\n\n")
-	}
-
-	// the "Packaging Changes" section
-	if len(doc.PackagingChanges) > 0 {
-		write("## Packaging / Installation\n\n")
-		for _, note := range doc.PackagingChanges {
-			writeNote(note)
+		key, err := json.Marshal(section.ID)
+		if err != nil {
+			return nil, err
 		}
-		write("\n\n")
-	}
+		buf.Write(key)
+		buf.WriteString(":")
 
-	// the "Duplicate Notes" section
-	if len(doc.Duplicates) > 0 {
-		write("## Notes From Multiple SIGs\n\n")
-		for header, notes := range doc.Duplicates {
-			write(fmt.Sprintf("### %s\n\n", header))
-			for _, note := range notes {
-				writeNote(note)
+		var value interface{} = section.Notes
+		if section.Grouped {
+			grouped := map[string][]*ReleaseNote{}
+			for _, group := range section.Groups {
+				grouped[group.Key] = group.Notes
 			}
-			write("\n")
+			value = grouped
 		}
-		write("\n")
-	}
-
-	// each SIG gets a section (in alphabetical order)
-	if len(sortedSIGs) > 0 {
-		write("## Notes from Individual SIGs\n\n")
-		for _, sig := range sortedSIGs {
-			write("### SIG " + prettySIG(sig) + "\n\n")
-			for _, note := range doc.SIGs[sig] {
-				writeNote(note)
-			}
-			write("\n")
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
 		}
-		write("\n\n")
+		buf.Write(data)
 	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
 
-	// the "Bug Fixes" section
-	if len(doc.BugFixes) > 0 {
-		write("## Bug Fixes\n\n")
-		for _, note := range doc.BugFixes {
-			writeNote(note)
-		}
-		write("\n\n")
-	}
+// RenderMarkdown accepts a Document and writes a version of that document to
+// supplied io.Writer in markdown format, using DefaultTemplate.
+func RenderMarkdown(doc *Document, w io.Writer) error {
+	return RenderTemplate(doc, defaultTemplate, w)
+}
 
-	// we call the uncategorized notes "Other Notable Changes". ideally these
-	// notes would at least have a SIG label.
-	if len(doc.Uncategorized) > 0 {
-		write("## Other Notable Changes\n\n")
-		for _, note := range doc.Uncategorized {
-			writeNote(note)
-		}
-		write("\n\n")
+// RenderJSON accepts a Document and writes it to the supplied io.Writer as
+// indented JSON.
+func RenderJSON(doc *Document, w io.Writer) error {
+	return writeJSON(doc, w)
+}
+
+// RenderNotesJSON writes a list of release notes to the supplied io.Writer as
+// indented JSON, without organizing them into a Document first.
+func RenderNotesJSON(notes []*ReleaseNote, w io.Writer) error {
+	if notes == nil {
+		notes = []*ReleaseNote{}
 	}
+	return writeJSON(notes, w)
+}
 
-	return err
+func writeJSON(v interface{}, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
 }
 
 // prettySIG takes a sig name as parsed by the `sig-foo` label and returns a
@@ -1,25 +1,140 @@
 package notes
 
 import (
-	"os"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kolide/kit/logutil"
 	"github.com/stretchr/testify/require"
 )
 
 func TestDocument(t *testing.T) {
-	client := githubClient(t)
+	fake, source := newFakeGitHub(t)
 	logger := logutil.NewCLILogger(true)
 
-	notes, err := ListReleaseNotes(client, logger, v1_11_0, "e92ea04edb286efe76caa86183fc00850a936f74")
+	// the note of the commit by netdatabot is left out too
+	notes, err := ListReleaseNotes(source, logger, "v1.0.0", "v1.1.0")
 	require.NoError(t, err)
-	require.Len(t, notes, 14)
+	require.Len(t, notes, 8)
+
+	// every PR and issue is only fetched once
+	for _, number := range []int{101, 102, 103, 104, 107, 110, 111, 112} {
+		require.Equal(t, 1, fake.requestCount(fmt.Sprintf("/repos/netdata/netdata/pulls/%d", number)))
+	}
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/issues/90"))
+
+	// the PR of the commit by netdatabot isn't even fetched
+	require.Zero(t, fake.requestCount("/repos/netdata/netdata/pulls/105"))
 
 	doc, err := CreateDocument(notes)
 	require.NoError(t, err)
+	require.Len(t, doc.Section("action_required").Notes, 1)
+	require.Len(t, doc.Section("new_features").Notes, 1)
+	require.Len(t, doc.Section("api_changes").Notes, 1)
+	require.Len(t, doc.Section("packaging_changes").Notes, 1)
+	require.Len(t, doc.Section("duplicate_notes").Groups, 1)
+	require.Equal(t, "SIG Health, and SIG Web", doc.Section("duplicate_notes").Groups[0].Title)
+	require.Len(t, doc.Section("sigs").Groups, 1)
+	require.Equal(t, "web", doc.Section("sigs").Groups[0].Key)
+	require.Len(t, doc.Section("bug_fixes").Notes, 1)
+	require.Len(t, doc.Section("uncategorized").Notes, 1)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderMarkdown(doc, buf))
+	for _, section := range []string{
+		"## Action Required",
+		"## New Features",
+		"## Documentation",
+		"## Packaging / Installation",
+		"### SIG Health, and SIG Web",
+		"### SIG Web",
+		"## Bug Fixes",
+		"## Other Notable Changes",
+	} {
+		require.Contains(t, buf.String(), section)
+	}
+	require.Contains(t, buf.String(), "- Add Prometheus remote write exporter ([#101](https://github.com/netdata/netdata/pull/101), [@alice](https://github.com/alice), closes [#90](https://github.com/netdata/netdata/issues/90))")
+}
+
+func TestDocumentDependencyUpdates(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	logger := logutil.NewCLILogger(true)
+
+	config := DefaultConfig()
+	config.Sections = append(config.Sections, DependencyUpdatesSection())
+
+	// the note of the commit by netdatabot is collected rather than left out
+	notes, err := ListReleaseNotes(source, logger, "v1.0.0", "v1.1.0", WithConfig(config))
+	require.NoError(t, err)
+	require.Len(t, notes, 9)
+
+	doc, err := CreateDocument(notes, WithConfig(config))
+	require.NoError(t, err)
+	updates := doc.Section("dependency_updates")
+	require.Len(t, updates.Notes, 1)
+	require.Equal(t, 105, updates.Notes[0].PrNumber)
+	require.True(t, updates.Notes[0].Bot)
+	require.Len(t, doc.Section("uncategorized").Notes, 1)
 
-	require.NoError(t, RenderMarkdown(doc, os.Stdout))
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderMarkdown(doc, buf))
+	require.Contains(t, buf.String(), "## Dependency Updates\n\n<details>\n<summary>1 change</summary>\n\n- ")
+	require.True(t, strings.HasSuffix(buf.String(), "</details>\n\n"))
+
+	buf.Reset()
+	require.NoError(t, RenderHTML(doc, buf))
+	require.Contains(t, buf.String(), "<details>\n<summary>1 change</summary>\n<ul>")
+}
+
+// documentRenderers are the renderers whose output is compared against the
+// golden files in testdata/documents, by the extension of the golden files.
+var documentRenderers = map[string]func(*Document, io.Writer) error{
+	".json": RenderJSON,
+	".md":   RenderMarkdown,
+	".html": func(doc *Document, w io.Writer) error {
+		return RenderHTML(doc, w, WithHTMLTitle("Netdata v1.1.0"))
+	},
+	".fragment.html": func(doc *Document, w io.Writer) error {
+		return RenderHTML(doc, w, WithHTMLFragment(true))
+	},
+	".changelog.md": func(doc *Document, w io.Writer) error {
+		return RenderChangelog(doc, "1.1.0", time.Date(2020, 3, 13, 0, 0, 0, 0, time.UTC), w)
+	},
+}
+
+// TestDocumentGolden renders a document for every set of notes in
+// testdata/notes with every renderer, and compares the output against the
+// golden files in testdata/documents.
+func TestDocumentGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "notes", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(input)
+			require.NoError(t, err)
+			notes := []*ReleaseNote{}
+			require.NoError(t, json.Unmarshal(data, &notes))
+
+			doc, err := CreateDocument(notes)
+			require.NoError(t, err)
+
+			for ext, render := range documentRenderers {
+				buf := &bytes.Buffer{}
+				require.NoError(t, render(doc, buf))
+				requireGolden(t, filepath.Join("testdata", "documents", name+ext), buf.Bytes())
+			}
+		})
+	}
 }
 
 func TestPrettySIG(t *testing.T) {
@@ -37,3 +152,22 @@ func TestPrettySIG(t *testing.T) {
 		require.Equal(t, expected, (prettySIG(input)))
 	}
 }
+
+func TestRenderNotesJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderNotesJSON(nil, buf))
+	require.Equal(t, "[]\n", buf.String())
+
+	notes := []*ReleaseNote{{
+		Commit:   "2f22765d04931a078909145ca628d2264c852d7d",
+		Text:     "Add Prometheus remote write exporter",
+		PrNumber: 101,
+		Areas:    []string{"exporting"},
+		Feature:  true,
+	}}
+	buf.Reset()
+	require.NoError(t, RenderNotesJSON(notes, buf))
+	decoded := []*ReleaseNote{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, notes, decoded)
+}
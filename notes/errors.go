@@ -0,0 +1,19 @@
+package notes
+
+import "github.com/pkg/errors"
+
+// The errors that are returned for commits that have no release note, which
+// can be told apart from the errors of the Source with errors.Is, even when
+// they are wrapped.
+var (
+	// ErrNoPR means that a commit wasn't merged in a PR, such as a commit that
+	// was pushed to the branch directly.
+	ErrNoPR = errors.New("no PR found for commit")
+
+	// ErrNoIssue means that a PR doesn't close any issue.
+	ErrNoIssue = errors.New("no issue found for PR")
+
+	// ErrNoNote means that a PR has no release note, because there is no text
+	// for it or the PR opted out of release notes.
+	ErrNoNote = errors.New("no release note found")
+)
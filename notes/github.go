@@ -0,0 +1,431 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// shaExp matches full commit SHAs, which unlike branches and tags always point
+// to the same commit.
+var shaExp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GitHubSource is a Source backed by the GitHub API. Every object it fetches is
+// remembered for the lifetime of the source, so that each of them is only
+// fetched once no matter how many times it is needed, and it can be kept in an
+// on-disk cache across runs too.
+type GitHubSource struct {
+	client   *github.Client
+	cacheDir string
+	memo     *memo
+}
+
+// NewGitHubSource creates a Source that uses the given GitHub API client. If
+// cacheDir isn't empty, commits, PRs and issues are cached on disk under it.
+// Cached PRs and issues are revalidated with conditional requests, which don't
+// count against the rate limit when nothing changed.
+func NewGitHubSource(client *github.Client, cacheDir string) *GitHubSource {
+	return &GitHubSource{
+		client:   client,
+		cacheDir: cacheDir,
+		memo:     newMemo(),
+	}
+}
+
+// GetCommit implements Source.
+func (s *GitHubSource) GetCommit(ctx context.Context, org, repo, rev string) (*github.RepositoryCommit, error) {
+	// only commits named by their SHA can be cached, since refs move
+	key := fmt.Sprintf("%s/%s/commits/%s", org, repo, rev)
+	commit, err := s.memo.do(key, func() (interface{}, error) {
+		commit := &github.RepositoryCommit{}
+		if shaExp.MatchString(rev) && s.loadCached(key, commit) {
+			return commit, nil
+		}
+
+		commit, _, err := s.client.Repositories.GetCommit(ctx, org, repo, rev)
+		if err != nil {
+			return nil, err
+		}
+		if shaExp.MatchString(rev) {
+			return commit, s.storeCached(key, commit)
+		}
+		return commit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commit.(*github.RepositoryCommit), nil
+}
+
+// ListCommits implements Source. The commits are listed with the compare API,
+// which resolves the range by ancestry.
+func (s *GitHubSource) ListCommits(ctx context.Context, org, repo, start, end string) ([]*github.RepositoryCommit, error) {
+	// the commits between two SHAs never change, so they are cached as they are
+	key := fmt.Sprintf("%s/%s/commits/%s..%s", org, repo, start, end)
+	commits := []*github.RepositoryCommit{}
+	if s.loadCached(key, &commits) {
+		return commits, nil
+	}
+
+	for page := 1; page != 0; {
+		comparison, resp, err := s.compareCommits(ctx, org, repo, start, end, page)
+		if err != nil {
+			return nil, err
+		}
+		for i := range comparison.Commits {
+			commits = append(commits, &comparison.Commits[i])
+		}
+		page = resp.NextPage
+	}
+
+	if err := s.storeCached(key, commits); err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// compareCommits fetches a single page of the comparison between two commits.
+// The compare endpoint truncates the commit list unless it is paginated, but
+// go-github's CompareCommits doesn't accept list options, so the request is
+// built by hand.
+func (s *GitHubSource) compareCommits(
+	ctx context.Context,
+	org,
+	repo,
+	base,
+	head string,
+	page int,
+) (*github.CommitsComparison, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/compare/%v...%v?page=%d&per_page=100", org, repo, base, head, page)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comparison := new(github.CommitsComparison)
+	resp, err := s.client.Do(ctx, req, comparison)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return comparison, resp, nil
+}
+
+// IsAncestor implements AncestrySource. The commits are compared once rather
+// than listed: ancestor is reachable from sha if it isn't ahead of it by any
+// commit.
+func (s *GitHubSource) IsAncestor(ctx context.Context, org, repo, ancestor, sha string) (bool, error) {
+	comparison, _, err := s.compareCommits(ctx, org, repo, sha, ancestor, 1)
+	if err != nil {
+		return false, err
+	}
+	return comparison.GetAheadBy() == 0, nil
+}
+
+// ListTags implements Source.
+func (s *GitHubSource) ListTags(ctx context.Context, org, repo string) ([]string, error) {
+	tags := []string{}
+	lo := &github.ListOptions{
+		Page:    1,
+		PerPage: 100,
+	}
+	for lo.Page != 0 {
+		page, resp, err := s.client.Repositories.ListTags(ctx, org, repo, lo)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page {
+			tags = append(tags, tag.GetName())
+		}
+		lo.Page = resp.NextPage
+	}
+	return tags, nil
+}
+
+// PullRequestForCommit implements Source. The PR number is parsed from the
+// commit message with PRNumbersFromMessage, trying every number it returns until
+// one of them is a merged PR. When the message doesn't refer to one, such as for
+// rebase-merged commits, the PRs that GitHub associates with the commit are
+// listed instead, and the merged one is returned. PRs that weren't merged, such
+// as those whose commits were pushed to the branch directly, are never
+// returned.
+func (s *GitHubSource) PullRequestForCommit(ctx context.Context, org, repo string, commit *github.RepositoryCommit) (*github.PullRequest, error) {
+	for _, number := range PRNumbersFromMessage(commit.GetCommit().GetMessage()) {
+		pr, err := s.getPullRequest(ctx, org, repo, number)
+		if err == nil {
+			if isMerged(pr) {
+				return pr, nil
+			}
+			continue
+		}
+		// the number may be that of an issue, or a typo
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	number, err := s.associatedPullRequest(ctx, org, repo, commit.GetSHA())
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		return nil, errors.Wrapf(ErrNoPR, "commit %s", commit.GetSHA())
+	}
+	return s.getPullRequest(ctx, org, repo, number)
+}
+
+// getPullRequest fetches the PR with the given number.
+func (s *GitHubSource) getPullRequest(ctx context.Context, org, repo string, number int) (*github.PullRequest, error) {
+	// The key doubles as the API path of the PR.
+	key := fmt.Sprintf("%s/%s/pulls/%d", org, repo, number)
+	pr, err := s.memo.do(key, func() (interface{}, error) {
+		pr := &github.PullRequest{}
+		return pr, s.getCached(ctx, key, "repos/"+key, pr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr.(*github.PullRequest), nil
+}
+
+// associatedPullRequest returns the number of the merged PR that GitHub
+// associates with a commit, or 0 if there is none. The PR is then
+// fetched on its own like any other, so that it is cached the same way. APIs
+// that don't support listing the PRs of a commit, such as those of older GitHub
+// Enterprise instances, are treated as if there were none.
+func (s *GitHubSource) associatedPullRequest(ctx context.Context, org, repo, sha string) (int, error) {
+	key := fmt.Sprintf("%s/%s/commits/%s/pulls", org, repo, sha)
+	number, err := s.memo.do(key, func() (interface{}, error) {
+		prs := []*github.PullRequest{}
+		if !s.loadCached(key, &prs) {
+			req, err := s.client.NewRequest("GET", "repos/"+key, nil)
+			if err != nil {
+				return 0, err
+			}
+			// listing the PRs of a commit is a preview of the API
+			req.Header.Set("Accept", grootPreview)
+			if _, err := s.client.Do(ctx, req, &prs); err != nil {
+				if isNotFound(err) {
+					return 0, nil
+				}
+				return 0, err
+			}
+			// commits can be associated with PRs later, so only the PRs that
+			// were found are cached
+			if len(prs) > 0 {
+				if err := s.storeCached(key, prs); err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		for _, pr := range prs {
+			if isMerged(pr) {
+				return pr.GetNumber(), nil
+			}
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return number.(int), nil
+}
+
+// isMerged reports whether a PR was merged. The PRs of a commit don't have
+// the merged field, only the time they were merged at.
+func isMerged(pr *github.PullRequest) bool {
+	return pr.GetMerged() || pr.MergedAt != nil
+}
+
+// grootPreview is the media type of the preview API that lists the PRs
+// associated with a commit.
+const grootPreview = "application/vnd.github.groot-preview+json"
+
+var (
+	// mergePRExp matches the message of the merge commit of a PR, such as
+	// "Merge pull request #123 from owner/branch".
+	mergePRExp = regexp.MustCompile(`^Merge pull request #(\d+) from `)
+
+	// prRefExp matches the PR number that GitHub appends to the title of
+	// squashed commits, such as "(#123)".
+	prRefExp = regexp.MustCompile(`\(#(\d+)\)`)
+)
+
+// PRNumbersFromMessage returns the numbers of the PRs that a commit message
+// refers to, most likely first. The message of a merge commit names its PR, and
+// GitHub appends the PR number to the title of squashed commits, after any other
+// PR numbers in the title, such as those of reverted commits. Only the first
+// line of the message is considered, since the rest often lists the commits of
+// other PRs.
+func PRNumbersFromMessage(message string) []int {
+	title := strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+
+	numbers := []int{}
+	add := func(s string) {
+		number, err := strconv.Atoi(s)
+		if err == nil && !hasInt(numbers, number) {
+			numbers = append(numbers, number)
+		}
+	}
+	if match := mergePRExp.FindStringSubmatch(title); match != nil {
+		add(match[1])
+	}
+	refs := prRefExp.FindAllStringSubmatch(title, -1)
+	for i := len(refs) - 1; i >= 0; i-- {
+		add(refs[i][1])
+	}
+	return numbers
+}
+
+// hasInt reports whether x is in a.
+func hasInt(a []int, x int) bool {
+	for _, n := range a {
+		if n == x {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound reports whether an error of the GitHub API means that an object
+// or an endpoint doesn't exist.
+func isNotFound(err error) bool {
+	resp, ok := err.(*github.ErrorResponse)
+	if !ok || resp.Response == nil {
+		return false
+	}
+	switch resp.Response.StatusCode {
+	case http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// GetIssue implements Source.
+func (s *GitHubSource) GetIssue(ctx context.Context, org, repo string, number int) (*github.Issue, error) {
+	key := fmt.Sprintf("%s/%s/issues/%d", org, repo, number)
+	issue, err := s.memo.do(key, func() (interface{}, error) {
+		issue := &github.Issue{}
+		return issue, s.getCached(ctx, key, "repos/"+key, issue)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issue.(*github.Issue), nil
+}
+
+// closingIssuesQuery is the GraphQL query for the issues that a PR closes,
+// which include those that were linked to it in the GitHub UI.
+const closingIssuesQuery = `query($org: String!, $repo: String!, $number: Int!) {
+  repository(owner: $org, name: $repo) {
+    pullRequest(number: $number) {
+      closingIssuesReferences(first: 100) {
+        nodes {
+          number
+          repository {
+            name
+            owner { login }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// LinkedIssues implements LinkedIssuesSource with the closingIssuesReferences
+// of the GraphQL API. The GraphQL API requires authentication, and older GitHub
+// Enterprise instances don't support closingIssuesReferences, in which case no
+// issues are linked. Any other error of the query, such as the rate limit being
+// exceeded, is returned. Since issues can be linked at any time, they aren't cached
+// on disk.
+func (s *GitHubSource) LinkedIssues(ctx context.Context, org, repo string, number int) ([]IssueRef, error) {
+	key := fmt.Sprintf("%s/%s/pulls/%d/closing_issues", org, repo, number)
+	refs, err := s.memo.do(key, func() (interface{}, error) {
+		// the GraphQL endpoint of GitHub Enterprise is next to the v3 API
+		u := "graphql"
+		if strings.HasSuffix(s.client.BaseURL.Path, "/v3/") {
+			u = "../graphql"
+		}
+		req, err := s.client.NewRequest("POST", u, map[string]interface{}{
+			"query": closingIssuesQuery,
+			"variables": map[string]interface{}{
+				"org":    org,
+				"repo":   repo,
+				"number": number,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		resp := struct {
+			Data struct {
+				Repository struct {
+					PullRequest struct {
+						ClosingIssuesReferences struct {
+							Nodes []struct {
+								Number     int
+								Repository struct {
+									Name  string
+									Owner struct{ Login string }
+								}
+							}
+						}
+					}
+				}
+			}
+			Errors []struct {
+				Type       string
+				Message    string
+				Extensions struct{ Code string }
+			}
+		}{}
+		if _, err := s.client.Do(ctx, req, &resp); err != nil {
+			if isNotFound(err) || isUnauthorized(err) {
+				return []IssueRef{}, nil
+			}
+			return nil, err
+		}
+		if len(resp.Errors) > 0 {
+			messages := []string{}
+			for _, e := range resp.Errors {
+				if e.Extensions.Code != "undefinedField" {
+					messages = append(messages, e.Message)
+				}
+			}
+			if len(messages) == 0 {
+				return []IssueRef{}, nil
+			}
+			return nil, errors.Errorf("error querying the closing issues: %s", strings.Join(messages, "; "))
+		}
+
+		refs := []IssueRef{}
+		for _, node := range resp.Data.Repository.PullRequest.ClosingIssuesReferences.Nodes {
+			refs = append(refs, IssueRef{
+				Org:    node.Repository.Owner.Login,
+				Repo:   node.Repository.Name,
+				Number: node.Number,
+			})
+		}
+		return refs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs.([]IssueRef), nil
+}
+
+// isUnauthorized reports whether an error of the GitHub API means that the
+// request needs to be authenticated.
+func isUnauthorized(err error) bool {
+	resp, ok := err.(*github.ErrorResponse)
+	return ok && resp.Response != nil && resp.Response.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,247 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// The commits of the recorded netdata/netdata history in testdata/github. The
+// range v1.0.0..v1.1.0 covers every kind of note that ends up in a document.
+const (
+	v1_0_0            = "7ac19ee157556944f6939fe82286468b89688712"
+	featureCommit     = "2f22765d04931a078909145ca628d2264c852d7d" // #101, closes a feature request
+	bugFixCommit      = "6b1f53303a732ccc8c6aae6640399827c15250e3" // #102, kind/bug
+	docsCommit        = "a625406f6977d45c1391b078f4d3656e0b75bfcb" // #103, area/docs
+	packagingCommit   = "e4666a670f042877c67a84473a71675ee0950a08" // #104, area/packaging
+	botCommit         = "8dc29fc58c0bd99068c2e5c752aa61521d4f11ce" // #105, by netdatabot
+	noChangelogCommit = "555c3f9218ba41a596519c8f01708a0ec9ef821b" // #106, no changelog
+	actionCommit      = "dd61a9b593df63335dc0acf0fd4349662b30756d" // #107, action required
+	mergeCommit       = "9f84ad6b89dc26670c0d6e7a3f81093b41c04438" // no PR
+	noneCommit        = "68ee74f7d6afe0164fe0f1197aa9177c946d8834" // #109, NONE release note
+	duplicateCommit   = "48c7489aa2e8309a658e9b785074e360a5eff369" // #110, two SIGs
+	sigCommit         = "9e8adf58ef5b87814490a4fe0cfaacd8f96effc2" // #111, sig/web
+	v1_1_0            = "2e5f2917a754dae6815d67b4d0da759259f335e1" // #112, no labels
+)
+
+// The commits of testdata/github that aren't in the history, with the other
+// messages that PRs are merged with.
+const (
+	mergePRCommit  = "78245ff39ef39ace0a9ee823a1a184ca7a3e5caa" // merge commit of #102
+	revertCommit   = "486766b6ea4c4039937db945d26b80c982a4cc26" // #112, reverts #102
+	rebaseCommit   = "5e10861e54e5cda6ce304f264149f03f4afd8ed9" // rebase-merged in #111
+	staleRefCommit = "4c28c9a23c71ba5935177341fba03b686050b383" // refers to #999, which isn't a PR
+	unmergedCommit = "82a0774ef0857068abf5f5c44e63ebc44b3409a7" // only linked to #113, which isn't merged
+)
+
+// fakeGitHub is a GitHub API server that replays the responses recorded under
+// testdata/github, where every response is stored in a file named after the
+// path of the request. Page N > 1 of a paginated response is stored with a
+// .pageN suffix, and is linked from the previous page.
+type fakeGitHub struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int
+	accept   map[string]string
+}
+
+// newFakeGitHub starts a fakeGitHub, which is stopped when the test completes,
+// and returns a GitHubSource that talks to it.
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *GitHubSource) {
+	f := &fakeGitHub{requests: map[string]int{}, accept: map[string]string{}}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(f.server.URL + "/")
+	return f, NewGitHubSource(client, "")
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests[r.URL.Path]++
+	f.accept[r.URL.Path] = r.Header.Get("Accept")
+	f.mu.Unlock()
+
+	// the responses to the GraphQL query for the closing issues of a PR are
+	// stored under graphql, named after the PR
+	if r.URL.Path == "/graphql" {
+		query := struct {
+			Variables struct {
+				Org    string
+				Repo   string
+				Number int
+			}
+		}{}
+		json.NewDecoder(r.Body).Decode(&query)
+		v := query.Variables
+		r.URL.Path = fmt.Sprintf("/graphql/%s/%s/pulls/%d", v.Org, v.Repo, v.Number)
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == 0 {
+		page = 1
+	}
+
+	data, err := ioutil.ReadFile(f.fixture(r.URL.Path, page))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found", "documentation_url": "https://developer.github.com/v3"}`)
+		return
+	}
+
+	if _, err := os.Stat(f.fixture(r.URL.Path, page+1)); err == nil {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, f.server.URL, next.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// fixture returns the file that the response to a page of the request path is
+// recorded in.
+func (f *fakeGitHub) fixture(path string, page int) string {
+	name := filepath.FromSlash(strings.TrimPrefix(path, "/"))
+	if page > 1 {
+		name = fmt.Sprintf("%s.page%d", name, page)
+	}
+	return filepath.Join("testdata", "github", name+".json")
+}
+
+// requestCount returns the number of requests that were made for path.
+func (f *fakeGitHub) requestCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func TestGitHubSourceListCommits(t *testing.T) {
+	fake, source := newFakeGitHub(t)
+
+	// the comparison is split over two pages
+	commits, err := ListCommits(source, "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+	require.Len(t, commits, 12)
+	require.Equal(t, featureCommit, commits[0].GetSHA())
+	require.Equal(t, v1_1_0, commits[11].GetSHA())
+	require.Equal(t, 2, fake.requestCount(fmt.Sprintf("/repos/netdata/netdata/compare/%s...%s", v1_0_0, v1_1_0)))
+
+}
+
+func TestGitHubSourceResolveRevision(t *testing.T) {
+	_, source := newFakeGitHub(t)
+
+	sha, err := ResolveRevision(source, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, v1_1_0, sha)
+
+	sha, err = ResolveRevision(source, "v1.1.0~12")
+	require.NoError(t, err)
+	require.Equal(t, v1_0_0, sha)
+}
+
+func TestGitHubSourcePreviousTag(t *testing.T) {
+	fake, source := newFakeGitHub(t)
+
+	tag, err := PreviousTag(source, "v1.1.0")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", tag)
+
+	// HEAD isn't a version, so the previous tag is found by ancestry, with a
+	// single comparison of HEAD and the tag
+	tag, err = PreviousTag(source, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", tag)
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/compare/"+v1_1_0+"..."+v1_0_0))
+}
+
+func TestGitHubSourcePullRequestForCommit(t *testing.T) {
+	fake, source := newFakeGitHub(t)
+	ctx := context.Background()
+
+	for sha, number := range map[string]int{
+		featureCommit: 101,
+		mergePRCommit: 102,
+		revertCommit:  112,
+		rebaseCommit:  111,
+	} {
+		commit, err := source.GetCommit(ctx, "netdata", "netdata", sha)
+		require.NoError(t, err)
+		pr, err := source.PullRequestForCommit(ctx, "netdata", "netdata", commit)
+		require.NoError(t, err, sha)
+		require.Equal(t, number, pr.GetNumber(), sha)
+	}
+
+	// the PRs of a commit are only listed when its message doesn't name one,
+	// with the preview media type
+	require.Zero(t, fake.requestCount("/repos/netdata/netdata/commits/"+featureCommit+"/pulls"))
+	path := "/repos/netdata/netdata/commits/" + rebaseCommit + "/pulls"
+	require.Equal(t, 1, fake.requestCount(path))
+	require.Equal(t, "application/vnd.github.groot-preview+json", fake.accept[path])
+
+	// #999 isn't a PR, and the commit isn't associated with one either
+	commit, err := source.GetCommit(ctx, "netdata", "netdata", staleRefCommit)
+	require.NoError(t, err)
+	_, err = source.PullRequestForCommit(ctx, "netdata", "netdata", commit)
+	require.True(t, errors.Is(err, ErrNoPR), err)
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/pulls/999"))
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/commits/"+staleRefCommit+"/pulls"))
+
+	// #113 is a PR, but it wasn't merged, and neither are the PRs that the
+	// commit is associated with
+	commit, err = source.GetCommit(ctx, "netdata", "netdata", unmergedCommit)
+	require.NoError(t, err)
+	_, err = source.PullRequestForCommit(ctx, "netdata", "netdata", commit)
+	require.True(t, errors.Is(err, ErrNoPR), err)
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/pulls/113"))
+	require.Equal(t, 1, fake.requestCount("/repos/netdata/netdata/commits/"+unmergedCommit+"/pulls"))
+}
+
+func TestPRNumbersFromMessage(t *testing.T) {
+	cases := map[string][]int{
+		"Add Prometheus remote write exporter (#101)":                            {101},
+		"Merge pull request #102 from bob/fix-freebsd\n\nFix crash (#90)":        {102},
+		"Revert \"Fix crash in apps.plugin on FreeBSD (#102)\" (#112)":           {112, 102},
+		"Cache the static files in memory\r\n\r\n* Part of #111 (#111)":          {},
+		"Merge branch 'fix-typo' into master":                                    {},
+		"Merge pull request #7 from bob/fix (#7)":                                {7},
+		"Update the changelog (#10) (#11)\n\n* Fix the typo (#9)":                {11, 10},
+		"Release v1.0.0 (#100)\r\n\r\nCo-authored-by: alice <alice@example.com>": {100},
+	}
+	for message, expected := range cases {
+		require.Equal(t, expected, PRNumbersFromMessage(message), message)
+	}
+}
+
+func TestGitHubSourceNotFound(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	ctx := context.Background()
+
+	commit, err := source.GetCommit(ctx, "netdata", "netdata", mergeCommit)
+	require.NoError(t, err)
+	_, err = source.PullRequestForCommit(ctx, "netdata", "netdata", commit)
+	require.True(t, errors.Is(err, ErrNoPR), err)
+
+	_, err = source.GetIssue(ctx, "netdata", "netdata", 404)
+	require.Error(t, err)
+	require.IsType(t, &github.ErrorResponse{}, err)
+}
@@ -0,0 +1,34 @@
+package notes
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// update regenerates the golden files rather than comparing against them:
+//
+//	go test ./notes -update
+//
+// Review the diff of testdata before committing the regenerated files.
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// requireGolden requires actual to match the contents of the golden file at
+// path, or writes actual to the golden file when the tests are run with -update.
+func requireGolden(t *testing.T, path string, actual []byte) {
+	t.Helper()
+
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, actual, 0644))
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run the tests with -update to create it")
+	require.Equal(t, string(expected), string(actual), "output differs from %s, run the tests with -update if the change is intended", path)
+}
@@ -0,0 +1,202 @@
+package notes
+
+import (
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DefaultHTMLTheme is a stylesheet for documents rendered by RenderHTML, which
+// can be embedded in them with WithHTMLTheme.
+const DefaultHTMLTheme = `
+.release-notes {
+  max-width: 50em;
+  margin: 2em auto;
+  padding: 0 1em;
+  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif;
+  line-height: 1.5;
+  color: #24292e;
+}
+.release-notes h2 {
+  padding-bottom: 0.3em;
+  border-bottom: 1px solid #eaecef;
+}
+.release-notes a {
+  color: #00ab44;
+  text-decoration: none;
+}
+.release-notes a:hover {
+  text-decoration: underline;
+}
+.release-notes .anchor {
+  margin-left: 0.25em;
+  color: #d1d5da;
+  visibility: hidden;
+}
+.release-notes h2:hover .anchor,
+.release-notes h3:hover .anchor {
+  visibility: visible;
+}
+.release-notes .pr,
+.release-notes .author,
+.release-notes .issues,
+.release-notes .sigs {
+  color: #586069;
+}
+`
+
+// htmlTemplate lays out documents in the same sections as DefaultTemplate. Every
+// section heading has an id to link to, and collapsed sections are wrapped in a
+// <details> element.
+const htmlTemplate = `
+{{- define "h2" -}}
+<h2 id="{{ anchor . }}">{{ . }}<a class="anchor" href="#{{ anchor . }}" aria-hidden="true">#</a></h2>
+{{ end -}}
+
+{{- define "h3" -}}
+<h3 id="{{ anchor . }}">{{ . }}<a class="anchor" href="#{{ anchor . }}" aria-hidden="true">#</a></h3>
+{{ end -}}
+
+{{- define "notes" -}}
+<ul>
+{{ range . -}}
+<li>{{ .Text }} <span class="pr">(<a href="{{ .PrUrl }}">#{{ .PrNumber }}</a>,</span> <span class="author"><a href="{{ .AuthorUrl }}">@{{ .Author }}</a>{{ if .Issues }},{{ else }}){{ end }}</span>
+{{- with .Issues }} <span class="issues">closes {{ range $i, $issue := . }}{{ if $i }}, {{ end }}<a href="{{ .Url }}">{{ .Ref }}</a>{{ end }})</span>{{ end }}
+{{- with courtesy . }} <span class="sigs">Courtesy of {{ . }}</span>{{ end }}</li>
+{{ end -}}
+</ul>
+{{ end -}}
+
+{{- define "section" -}}
+{{ if .Grouped -}}
+{{ range .Groups -}}
+{{ template "h3" .Title }}{{ template "notes" .Notes -}}
+{{ end -}}
+{{ else -}}
+{{ template "notes" .Notes -}}
+{{ end -}}
+{{ end -}}
+
+{{- define "document" -}}
+{{ range .Sections }}{{ if not .Empty -}}
+<section>
+{{ template "h2" .Title -}}
+{{ if .Collapsed -}}
+<details>
+<summary>{{ with len .AllNotes }}{{ . }} {{ if eq . 1 }}change{{ else }}changes{{ end }}{{ end }}</summary>
+{{ template "section" . -}}
+</details>
+{{ else -}}
+{{ template "section" . -}}
+{{ end -}}
+</section>
+{{ end }}{{ end -}}
+{{ end -}}
+
+{{- if .Fragment -}}
+<div class="release-notes">
+{{ if .Theme }}<style>{{ .Theme }}</style>
+{{ end -}}
+{{ template "document" .Document -}}
+</div>
+{{ else -}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+{{ if .Theme }}<style>{{ .Theme }}</style>
+{{ end -}}
+</head>
+<body>
+<main class="release-notes">
+<h1>{{ .Title }}</h1>
+{{ template "document" .Document -}}
+</main>
+</body>
+</html>
+{{ end -}}
+`
+
+// anchorExp matches the runs of characters that are left out of anchors.
+var anchorExp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// parsedHTMLTemplate is htmlTemplate, parsed once.
+var parsedHTMLTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
+	// anchor turns a heading into the id of its element
+	"anchor": func(title string) string {
+		return strings.Trim(anchorExp.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	},
+
+	// courtesy lists the SIGs that are credited for an important note, as
+	// RenderMarkdown does
+	"courtesy": func(note *ReleaseNote) string {
+		if !note.ActionRequired && !note.Feature {
+			return ""
+		}
+		return prettifySigList(append([]string{}, note.SIGs...))
+	},
+}).Parse(htmlTemplate))
+
+// htmlOption is a type which allows for the expression of HTML rendering
+// configuration via the "functional option" pattern.
+type htmlOption func(*htmlConfig)
+
+// htmlConfig is a configuration struct that is used to express optional
+// configuration for RenderHTML
+type htmlConfig struct {
+	title    string
+	fragment bool
+	theme    string
+}
+
+// WithHTMLTitle sets the title of a standalone HTML document.
+func WithHTMLTitle(title string) htmlOption {
+	return func(c *htmlConfig) {
+		c.title = title
+	}
+}
+
+// WithHTMLFragment renders the notes as an HTML fragment that can be embedded
+// in another page, rather than as a standalone HTML document, if fragment is
+// true.
+func WithHTMLFragment(fragment bool) htmlOption {
+	return func(c *htmlConfig) {
+		c.fragment = fragment
+	}
+}
+
+// WithHTMLTheme embeds a stylesheet, such as DefaultHTMLTheme, in the rendered
+// HTML. Its rules should apply to the elements inside the .release-notes
+// element, which wraps the notes. Nothing is embedded if css is empty.
+func WithHTMLTheme(css string) htmlOption {
+	return func(c *htmlConfig) {
+		c.theme = css
+	}
+}
+
+// RenderHTML accepts a Document and writes it to the supplied io.Writer as
+// HTML, in the same sections as RenderMarkdown. The text of the notes is
+// escaped, and their PR numbers, authors and the issues they close link to
+// GitHub.
+func RenderHTML(doc *Document, w io.Writer, opts ...htmlOption) error {
+	c := &htmlConfig{
+		title: "Release Notes",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return parsedHTMLTemplate.Execute(w, struct {
+		Document *Document
+		Title    string
+		Fragment bool
+		Theme    template.CSS
+	}{
+		Document: doc,
+		Title:    c.title,
+		Fragment: c.fragment,
+		Theme:    template.CSS(c.theme),
+	})
+}
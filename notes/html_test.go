@@ -0,0 +1,31 @@
+package notes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderHTMLTheme(t *testing.T) {
+	doc, err := CreateDocument([]*ReleaseNote{{
+		Text:      "Add a --disable-cloud option to the installer",
+		Author:    "frank",
+		AuthorUrl: "https://github.com/frank",
+		PrUrl:     "https://github.com/netdata/netdata/pull/112",
+		PrNumber:  112,
+	}})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderHTML(doc, buf))
+	require.NotContains(t, buf.String(), "<style>")
+
+	// the stylesheet is embedded as is, both in documents and fragments
+	for _, fragment := range []bool{false, true} {
+		buf.Reset()
+		require.NoError(t, RenderHTML(doc, buf, WithHTMLTheme(DefaultHTMLTheme), WithHTMLFragment(fragment)))
+		require.Contains(t, buf.String(), "<style>"+DefaultHTMLTheme+"</style>")
+		require.Equal(t, !fragment, bytes.HasPrefix(buf.Bytes(), []byte("<!DOCTYPE html>")))
+	}
+}
@@ -0,0 +1,217 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// IssueRef refers to an issue of a repository.
+type IssueRef struct {
+	Org    string
+	Repo   string
+	Number int
+}
+
+// String formats the reference the way GitHub does, such as
+// "netdata/netdata#123".
+func (r IssueRef) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Org, r.Repo, r.Number)
+}
+
+// from formats the reference from the given repository, which is only the
+// issue number if the issue is in that repository, such as "#123", and is
+// qualified with the org and repository otherwise, such as
+// "netdata/go.d.plugin#123".
+func (r IssueRef) from(org, repo string) string {
+	if strings.EqualFold(r.Org, org) && strings.EqualFold(r.Repo, repo) {
+		return fmt.Sprintf("#%d", r.Number)
+	}
+	return r.String()
+}
+
+// key identifies the issue, since the names of orgs and repositories are case
+// insensitive.
+func (r IssueRef) key() string {
+	return strings.ToLower(r.String())
+}
+
+// LinkedIssuesSource is implemented by the Sources that know which issues a
+// pull request is linked to besides those its description refers to, such as
+// the issues that were linked to it in the GitHub UI. IssuesFromPR uses it when
+// the Source implements it.
+type LinkedIssuesSource interface {
+	// LinkedIssues returns the issues that the pull request with the given
+	// number closes when it is merged.
+	LinkedIssues(ctx context.Context, org, repo string, number int) ([]IssueRef, error)
+}
+
+// LinkedIssue is an issue that the PR of a release note closes.
+type LinkedIssue struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+
+	// Ref is how the PR refers to the issue, such as "#123" for the issues of
+	// the same repository, or "netdata/go.d.plugin#123"
+	Ref string `json:"ref"`
+
+	// Url is a URL to the issue
+	Url string `json:"url"`
+
+	// Labels are the labels of the issue
+	Labels []string `json:"labels,omitempty"`
+}
+
+var (
+	// issueRefPattern matches a reference to an issue, which is either a number
+	// such as "#123", qualified with a repository such as "org/repo#123", or
+	// the URL of an issue such as "https://github.com/org/repo/issues/123".
+	issueRefPattern = `(?:https?://[^\s/]+/([\w.-]+)/([\w.-]+)/issues/|(?:([\w.-]+)/([\w.-]+))?#)(\d+)`
+
+	// issueRefExp matches an issue reference, with the org and repository of
+	// URLs, the org and repository of qualified numbers and the number as its
+	// groups.
+	issueRefExp = regexp.MustCompile(issueRefPattern)
+
+	// closingRefsExp matches a keyword that closes issues followed by a list of
+	// issue references, such as "Fixes #1, org/repo#2 and #3".
+	closingRefsExp = regexp.MustCompile(
+		`(?i)\b(?:` + CloseIssueKeywords + `):?\s+(` +
+			issueRefPattern + `(?:(?:\s*,\s*(?:and\s+)?|\s+and\s+)` + issueRefPattern + `)*)`,
+	)
+)
+
+// ParseIssueRefs returns the issues that the description of a PR closes, in
+// the order they are referred to. A reference follows one of the keywords that
+// GitHub closes issues with, and a keyword may be followed by a list of them,
+// such as
+//
+//	Fixes #1, fixes org/repo#2
+//	Closes #3, #4 and https://github.com/org/repo/issues/5
+//
+// References without an org and repository are to the issues of the given
+// repository. HTML comments, such as the instructions of PR templates, are
+// ignored.
+func ParseIssueRefs(org, repo, body string) []IssueRef {
+	body = htmlCommentExp.ReplaceAllString(body, "")
+
+	refs := []IssueRef{}
+	seen := map[string]bool{}
+	for _, list := range closingRefsExp.FindAllStringSubmatch(body, -1) {
+		for _, match := range issueRefExp.FindAllStringSubmatch(list[1], -1) {
+			number, err := strconv.Atoi(match[5])
+			if err != nil {
+				continue
+			}
+			ref := IssueRef{Org: org, Repo: repo, Number: number}
+			switch {
+			case match[1] != "":
+				ref.Org, ref.Repo = match[1], match[2]
+			case match[3] != "":
+				ref.Org, ref.Repo = match[3], match[4]
+			}
+			if !seen[ref.key()] {
+				seen[ref.key()] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// IssuesFromPR returns the issues that a PR closes: those its description
+// refers to, followed by those that the Source links it to, if it is a
+// LinkedIssuesSource. This is useful for going from a commit log to the
+// associated issues it either addresses, closes or fixes (which contains useful
+// info such as the type of issue the commit/PR was fixing/closing as well as
+// labels specific to the issues and not necessarily the pull request).
+//
+// References to pull requests rather than issues, and to issues that don't
+// exist or can't be accessed, such as those of private repositories, are left
+// out. ErrNoIssue is returned if no issues are left.
+//
+// Any other error, such as listing the linked issues or getting one of the
+// issues failing, only leaves out the issues it affects: the first one is
+// returned along with the issues that were found anyway.
+func IssuesFromPR(source Source, pr *github.PullRequest, opts ...githubApiOption) ([]*LinkedIssue, error) {
+	c := configFromOpts(opts...)
+
+	var firstErr error
+	refs := ParseIssueRefs(c.org, c.repo, pr.GetBody())
+	if linked, ok := source.(LinkedIssuesSource); ok {
+		more, err := linked.LinkedIssues(c.ctx, c.org, c.repo, pr.GetNumber())
+		if err != nil {
+			firstErr = errors.Wrapf(err, "error listing the linked issues of PR #%d", pr.GetNumber())
+		}
+		seen := map[string]bool{}
+		for _, ref := range refs {
+			seen[ref.key()] = true
+		}
+		for _, ref := range more {
+			if !seen[ref.key()] {
+				seen[ref.key()] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	issues := []*LinkedIssue{}
+	for _, ref := range refs {
+		logger := log.With(c.logger, "pr", pr.GetNumber(), "issue", ref.String())
+		issue, err := source.GetIssue(c.ctx, ref.Org, ref.Repo, ref.Number)
+		if err != nil {
+			if isNotFound(err) {
+				level.Debug(logger).Log("msg", "skipping a reference to an issue that can't be accessed")
+				continue
+			}
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "error getting issue %s", ref)
+			}
+			continue
+		}
+		if issue.IsPullRequest() {
+			level.Debug(logger).Log("msg", "skipping a reference to a PR")
+			continue
+		}
+
+		url := issue.GetHTMLURL()
+		if url == "" {
+			url = fmt.Sprintf("%s/%s/%s/issues/%d", c.webURL, ref.Org, ref.Repo, ref.Number)
+		}
+		issues = append(issues, &LinkedIssue{
+			Org:    ref.Org,
+			Repo:   ref.Repo,
+			Number: ref.Number,
+			Ref:    ref.from(c.org, c.repo),
+			Url:    url,
+			Labels: GetIssueLabels(issue),
+		})
+	}
+	if firstErr != nil {
+		return issues, firstErr
+	}
+	if len(issues) == 0 {
+		return nil, errors.Wrapf(ErrNoIssue, "PR #%d", pr.GetNumber())
+	}
+	return issues, nil
+}
+
+// labelsOfIssues returns the labels of all of the issues, without duplicates.
+func labelsOfIssues(issues []*LinkedIssue) []string {
+	var labels []string
+	for _, issue := range issues {
+		for _, label := range issue.Labels {
+			if !HasString(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
@@ -0,0 +1,129 @@
+package notes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueRefs(t *testing.T) {
+	ref := func(org, repo string, number int) IssueRef {
+		return IssueRef{Org: org, Repo: repo, Number: number}
+	}
+
+	cases := map[string]struct {
+		body     string
+		expected []IssueRef
+	}{
+		"keyword":   {body: "Fixes #90", expected: []IssueRef{ref("netdata", "netdata", 90)}},
+		"colon":     {body: "resolves: #90.", expected: []IssueRef{ref("netdata", "netdata", 90)}},
+		"no refs":   {body: "Fixes the crash in #90", expected: []IssueRef{}},
+		"reference": {body: "See #90", expected: []IssueRef{}},
+		"keywords": {
+			body:     "Fixes #90, fixes #91\r\nCloses #92",
+			expected: []IssueRef{ref("netdata", "netdata", 90), ref("netdata", "netdata", 91), ref("netdata", "netdata", 92)},
+		},
+		"list": {
+			body:     "Closes #90, #91 and #92, and #93",
+			expected: []IssueRef{ref("netdata", "netdata", 90), ref("netdata", "netdata", 91), ref("netdata", "netdata", 92), ref("netdata", "netdata", 93)},
+		},
+		"cross-repo": {
+			body:     "Fixes netdata/go.d.plugin#55 and #90",
+			expected: []IssueRef{ref("netdata", "go.d.plugin", 55), ref("netdata", "netdata", 90)},
+		},
+		"urls": {
+			body:     "Fixes https://github.com/netdata/go.d.plugin/issues/55, https://github.com/netdata/netdata/issues/90",
+			expected: []IssueRef{ref("netdata", "go.d.plugin", 55), ref("netdata", "netdata", 90)},
+		},
+		"pull request urls": {body: "Fixes https://github.com/netdata/netdata/pull/90", expected: []IssueRef{}},
+		"duplicates": {
+			body:     "Fixes #90\nCloses https://github.com/netdata/netdata/issues/90\nResolves Netdata/Netdata#90",
+			expected: []IssueRef{ref("netdata", "netdata", 90)},
+		},
+		"comments": {
+			body:     "<!-- Fixes #1 -->\nFixes #90",
+			expected: []IssueRef{ref("netdata", "netdata", 90)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ParseIssueRefs("netdata", "netdata", tc.body))
+		})
+	}
+}
+
+func TestIssuesFromPR(t *testing.T) {
+	fake, source := newFakeGitHub(t)
+	ctx := context.Background()
+
+	// the PR of v1.1.0 refers to a number, a cross-repo number and a URL, and
+	// has two linked issues, one of which it also refers to
+	commit, err := source.GetCommit(ctx, "netdata", "netdata", v1_1_0)
+	require.NoError(t, err)
+	pr, err := PRFromCommit(source, commit)
+	require.NoError(t, err)
+
+	issues, err := IssuesFromPR(source, pr)
+	require.NoError(t, err)
+	require.Equal(t, []*LinkedIssue{
+		{Org: "netdata", Repo: "netdata", Number: 93, Ref: "#93", Url: "https://github.com/netdata/netdata/issues/93", Labels: []string{"area/installer"}},
+		{Org: "netdata", Repo: "go.d.plugin", Number: 55, Ref: "netdata/go.d.plugin#55", Url: "https://github.com/netdata/go.d.plugin/issues/55", Labels: []string{"area/installer"}},
+		{Org: "netdata", Repo: "netdata", Number: 94, Ref: "#94", Url: "https://github.com/netdata/netdata/issues/94", Labels: []string{"kind/cleanup"}},
+		{Org: "netdata", Repo: "netdata", Number: 96, Ref: "#96", Url: "https://github.com/netdata/netdata/issues/96", Labels: []string{"area/cloud"}},
+	}, issues)
+	require.Equal(t, 1, fake.requestCount("/graphql"))
+
+	// PRs without linked issues, and references to issues that don't exist,
+	// leave nothing to fetch
+	issues, err = IssuesFromPR(source, &github.PullRequest{
+		Number: github.Int(101),
+		Body:   github.String("Fixes #90, #999"),
+	})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, 90, issues[0].Number)
+	require.Equal(t, "#90", issues[0].Ref)
+	require.Equal(t, "netdata/netdata#90", IssueRef{Org: "netdata", Repo: "netdata", Number: 90}.from("netdata", "go.d.plugin"))
+
+	_, err = IssuesFromPR(source, &github.PullRequest{Number: github.Int(103)})
+	require.True(t, errors.Is(err, ErrNoIssue), err)
+	_, err = IssuesFromPR(source, &github.PullRequest{Number: github.Int(103), Body: github.String("Fixes #999")})
+	require.True(t, errors.Is(err, ErrNoIssue), err)
+
+	// failed queries of the linked issues are errors, along with the issues
+	// that the description refers to, unless the GraphQL API doesn't support
+	// linked issues
+	issues, err = IssuesFromPR(source, &github.PullRequest{Number: github.Int(120), Body: github.String("Fixes #90")})
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrNoIssue), err)
+	require.Contains(t, err.Error(), "API rate limit exceeded")
+	require.Len(t, issues, 1)
+	_, err = IssuesFromPR(source, &github.PullRequest{Number: github.Int(121)})
+	require.True(t, errors.Is(err, ErrNoIssue), err)
+}
+
+func TestReleaseNoteIssues(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	commit, err := source.GetCommit(context.Background(), "netdata", "netdata", v1_1_0)
+	require.NoError(t, err)
+
+	// the PR has no labels of its own, so the note takes those of all of its
+	// issues
+	note, err := ReleaseNoteFromCommit(commit, source)
+	require.NoError(t, err)
+	require.Len(t, note.Issues, 4)
+	require.Equal(t, []string{"area/installer", "kind/cleanup", "area/cloud"}, note.IssueLabels)
+	require.Equal(t, []string{"installer", "cloud"}, note.Areas)
+	require.Equal(t, []string{"cleanup"}, note.Kinds)
+	require.Equal(t, "Add a --disable-cloud option to the installer ("+
+		"[#112](https://github.com/netdata/netdata/pull/112), "+
+		"[@frank](https://github.com/frank), "+
+		"closes [#93](https://github.com/netdata/netdata/issues/93), "+
+		"[netdata/go.d.plugin#55](https://github.com/netdata/go.d.plugin/issues/55), "+
+		"[#94](https://github.com/netdata/netdata/issues/94), "+
+		"[#96](https://github.com/netdata/netdata/issues/96))", note.Markdown)
+}
@@ -17,9 +17,7 @@ package notes
 import (
 	"context"
 	"fmt"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/go-kit/kit/log"
@@ -32,6 +30,36 @@ const (
 	CloseIssueKeywords = "Close|Closes|Closed|Fix|Fixes|Fixed|Resolve|Resolves|Resolved"
 )
 
+// NoteText is a strategy for where the text of release notes comes from.
+type NoteText string
+
+const (
+	// NoteTextBlock takes the text of the release-note block of the PR
+	// description, and leaves out the PRs without one.
+	NoteTextBlock NoteText = "block"
+
+	// NoteTextTitle takes the title of the PR.
+	NoteTextTitle NoteText = "title"
+
+	// NoteTextCommit takes the first line of the commit message, without the PR
+	// number that GitHub appends to squashed commits. The merge commits of PRs
+	// take the first line of their body, which is the title of the PR.
+	NoteTextCommit NoteText = "commit"
+
+	// NoteTextBlockOrTitle takes the text of the release-note block of the PR
+	// description, falling back to the title of the PR.
+	NoteTextBlockOrTitle NoteText = "block-or-title"
+)
+
+// ParseNoteText parses the name of a NoteText strategy.
+func ParseNoteText(s string) (NoteText, error) {
+	switch text := NoteText(s); text {
+	case NoteTextBlock, NoteTextTitle, NoteTextCommit, NoteTextBlockOrTitle:
+		return text, nil
+	}
+	return "", fmt.Errorf("unknown note text %q, must be one of block, title, commit or block-or-title", s)
+}
+
 // ReleaseNote is the type that represents the total sum of all the information
 // we've gathered about a single release note.
 type ReleaseNote struct {
@@ -66,6 +94,16 @@ type ReleaseNote struct {
 	// SIGs is a list of the labels beginning with sig/
 	SIGs []string `json:"sigs,omitempty"`
 
+	// Labels are all of the labels of the PR, which the note is categorized by,
+	// plus release-note-action-required if the PR has a block of that kind
+	Labels []string `json:"labels,omitempty"`
+
+	// Issues are the issues closed by the PR
+	Issues []*LinkedIssue `json:"issues,omitempty"`
+
+	// IssueLabels are the labels of the issues closed by the PR
+	IssueLabels []string `json:"issue_labels,omitempty"`
+
 	// Indicates whether or not a note will appear as a new feature
 	Feature bool `json:"feature,omitempty"`
 
@@ -73,8 +111,12 @@ type ReleaseNote struct {
 	Duplicate bool `json:"duplicate,omitempty"`
 
 	// ActionRequired indicates whether or not the release-note-action-required
-	// label was set on the PR
+	// label was set on the PR, or the PR has a block of that kind
 	ActionRequired bool `json:"action_required,omitempty"`
+
+	// Bot indicates whether the commit or the PR was authored by one of the
+	// excluded authors of the configuration, such as a dependency update bot
+	Bot bool `json:"bot,omitempty"`
 }
 
 // githubApiOption is a type which allows for the expression of API configuration
@@ -86,10 +128,17 @@ type githubApiOption func(*githubApiConfig)
 // githubApiConfig is a configuration struct that is used to express optional
 // configuration for GitHub API requests
 type githubApiConfig struct {
-	ctx    context.Context
-	org    string
-	repo   string
-	branch string
+	ctx         context.Context
+	org         string
+	repo        string
+	branch      string
+	webURL      string
+	concurrency int
+	config      *Config
+	noteText    NoteText
+	audit       *Audit
+	strict      bool
+	logger      log.Logger
 }
 
 // WithContext allows the caller to inject a context into GitHub API requests
@@ -123,45 +172,132 @@ func WithBranch(branch string) githubApiOption {
 	}
 }
 
+// WithWebURL allows the caller to override the base URL of the GitHub web
+// interface, such as that of a GitHub Enterprise instance. It is used to link to
+// PRs and authors when the API doesn't provide their URLs. By default, it is
+// "https://github.com".
+func WithWebURL(webURL string) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.webURL = strings.TrimRight(webURL, "/")
+	}
+}
+
+// WithConcurrency allows the caller to set how many commits are processed in
+// parallel, which bounds the number of concurrent GitHub API requests. By
+// default, it is 4.
+func WithConcurrency(concurrency int) githubApiOption {
+	return func(c *githubApiConfig) {
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		c.concurrency = concurrency
+	}
+}
+
+// WithConfig allows the caller to configure how release notes are categorized,
+// and which ones are left out. By default, it is DefaultConfig.
+func WithConfig(config *Config) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.config = config
+	}
+}
+
+// WithNoteText allows the caller to choose where the text of release notes
+// comes from. By default, it is NoteTextTitle.
+func WithNoteText(noteText NoteText) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.noteText = noteText
+	}
+}
+
+// WithStrict allows the caller to abort when the Source fails, such as when the
+// GitHub API returns an unexpected error, instead of leaving out the notes of
+// the commits it failed for, or the issues of their PRs. Commits without a PR
+// or a release note are left out either way.
+func WithStrict(strict bool) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.strict = strict
+	}
+}
+
+// WithLogger allows the caller to inject the logger that diagnostics are
+// written to. The messages about a commit have its SHA as the "sha" key, and
+// the numbers of its PR and issues as the "pr" and "issue" keys. By default,
+// nothing is logged. ListReleaseNotes and ListCommitsWithNotes log to the
+// logger they are given.
+func WithLogger(logger log.Logger) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.logger = logger
+	}
+}
+
+// WithAudit allows the caller to collect a record of what happened to every
+// commit while listing release notes.
+func WithAudit(audit *Audit) githubApiOption {
+	return func(c *githubApiConfig) {
+		c.audit = audit
+	}
+}
+
 // ListReleaseNotes produces a list of fully contextualized release notes
-// starting from a given commit SHA and ending at starting a given commit SHA.
+// starting from a given revision and ending at a given revision.
 func ListReleaseNotes(
-	client *github.Client,
+	source Source,
 	logger log.Logger,
 	start,
 	end string,
 	opts ...githubApiOption,
 ) ([]*ReleaseNote, error) {
-	commits, err := ListCommitsWithNotes(client, logger, start, end, opts...)
+	opts = append(opts[:len(opts):len(opts)], WithLogger(logger))
+	c := configFromOpts(opts...)
+
+	commits, err := ListCommitsWithNotes(source, logger, start, end, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	dedupeCache := map[string]struct{}{}
-	notes := []*ReleaseNote{}
-	for _, commit := range commits {
-		if commit.GetAuthor().GetLogin() == "netdatabot" {
-			continue
-		}
+	results := make([]*ReleaseNote, len(commits))
+	errs := make([]error, len(commits))
+	forEach(c.concurrency, len(commits), func(i int) {
+		results[i], errs[i] = ReleaseNoteFromCommit(commits[i], source, opts...)
+	})
 
-		note, err := ReleaseNoteFromCommit(commit, client, opts...)
-		if err != nil {
+	dedupeCache := map[string]string{}
+	notes := []*ReleaseNote{}
+	for i, note := range results {
+		sha := commits[i].GetSHA()
+		if errs[i] != nil {
+			if errors.Is(errs[i], ErrNoNote) {
+				c.audit.decide(sha, DecisionExcluded, "no release note text")
+				continue
+			}
+			if c.strict {
+				return nil, errs[i]
+			}
 			level.Error(logger).Log(
-				"err", err,
-				"msg", "error getting the release note from commit while listing release notes",
-				"sha", commit.GetSHA(),
+				"msg", "error getting the release note of a commit",
+				"sha", sha,
+				"err", errs[i],
 			)
+			c.audit.decide(sha, DecisionError, errs[i].Error())
 			continue
 		}
+		c.audit.update(sha, func(r *AuditRecord) { r.PrNumber = note.PrNumber })
 
-		if strings.TrimSpace(note.Text) == "NONE" {
+		// the PR may have been opened by an excluded author, even though the
+		// commit wasn't
+		if note.Bot && !c.config.collectsBots() {
+			c.audit.decide(sha, DecisionBot, fmt.Sprintf("PR opened by the excluded author %s", note.Author))
 			continue
 		}
 
-		if _, ok := dedupeCache[note.Text]; !ok {
-			notes = append(notes, note)
-			dedupeCache[note.Text] = struct{}{}
+		if first, ok := dedupeCache[note.Text]; ok {
+			c.audit.decide(sha, DecisionDedup, fmt.Sprintf("same note as commit %s", first))
+			continue
 		}
+		notes = append(notes, note)
+		dedupeCache[note.Text] = sha
+		c.audit.decide(sha, DecisionIncluded, "")
 	}
 
 	return notes, nil
@@ -169,60 +305,57 @@ func ListReleaseNotes(
 
 // NoteTextFromString returns the text of the release note given a string which
 // may contain the commit message, the PR description, etc.
-// This is generally the content inside the ```release-note ``` stanza.
+// This is the content of the release-note and release-note-action-required
+// blocks parsed by ParseNoteBlocks, one after the other if there are several of
+// them. Empty blocks are ignored, and so are dev-release-note blocks, which are
+// for developers rather than users.
 func NoteTextFromString(s string) (string, error) {
-	exps := []*regexp.Regexp{
-		regexp.MustCompile("```release-note\\r\\n(?P<note>.+)"),
-		regexp.MustCompile("```dev-release-note\\r\\n(?P<note>.+)"),
-		regexp.MustCompile("```\\r\\n(?P<note>.+)\\r\\n```"),
-		regexp.MustCompile("```release-note\n(?P<note>.+)\n```"),
-	}
-
-	for _, exp := range exps {
-		match := exp.FindStringSubmatch(s)
-		if len(match) == 0 {
+	texts := []string{}
+	for _, block := range ParseNoteBlocks(s) {
+		if block.Text == "" || block.Info == BlockDevReleaseNote {
 			continue
 		}
-		result := map[string]string{}
-		for i, name := range exp.SubexpNames() {
-			if i != 0 && name != "" {
-				result[name] = match[i]
-			}
-		}
-		note := strings.TrimRight(result["note"], "\r")
-		note = stripActionRequired(note)
+		note := stripActionRequired(block.Text)
 		note = stripStar(note)
-		return note, nil
+		texts = append(texts, note)
 	}
-
-	return "", errors.New("no matches found when parsing note text from commit string")
+	if len(texts) == 0 {
+		return "", errors.Wrap(ErrNoNote, "no release-note block")
+	}
+	return strings.Join(texts, "\n"), nil
 }
 
 // ReleaseNoteFromCommit produces a full contextualized release note given a
-// GitHub commit API resource.
-func ReleaseNoteFromCommit(commit *github.RepositoryCommit, client *github.Client, opts ...githubApiOption) (*ReleaseNote, error) {
-	pr, err := PRFromCommit(client, commit, opts...)
+// GitHub commit API resource. The text of the note is chosen with the NoteText
+// strategy. There is no note, and ErrNoNote is returned, if the PR opted out of
+// release notes, if the text is NONE, or if there is no text. ErrNoPR is
+// returned if the commit wasn't merged in a PR. Errors getting the issues that
+// the PR closes are ignored, and the note is produced without them, unless
+// WithStrict is set.
+func ReleaseNoteFromCommit(commit *github.RepositoryCommit, source Source, opts ...githubApiOption) (*ReleaseNote, error) {
+	c := configFromOpts(opts...)
+
+	pr, err := PRFromCommit(source, commit, opts...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error parsing release note from commit %s", commit.GetSHA())
 	}
 
-	var issue *github.Issue
-
-	issue, err = IssueFromPR(client, pr, opts...)
+	logger := log.With(c.logger, "sha", commit.GetSHA(), "pr", pr.GetNumber())
+	issues, err := IssuesFromPR(source, pr, opts...)
 	if err != nil {
-		if err.Error() == "no matches found when parsing Issue from PR" {
-			fmt.Fprintf(os.Stderr, "no Issue found for #%d\n", *pr.Number)
+		if errors.Is(err, ErrNoIssue) {
+			level.Debug(logger).Log("msg", "no issue found for the PR")
+		} else if c.strict {
+			return nil, errors.Wrapf(err, "error parsing release note from commit %s", commit.GetSHA())
+		} else {
+			level.Warn(logger).Log("msg", "error getting the issues of a PR", "err", err)
 		}
 	}
 
-	/* XXX: Disabled for now since we don't add release notes to commits (yet)
-	text, err := NoteTextFromString(pr.GetBody())
-	if err != nil {
-		return nil, err
+	text, ok := noteText(c.noteText, commit, pr)
+	if !ok {
+		return nil, errors.Wrapf(ErrNoNote, "PR #%d", pr.GetNumber())
 	}
-	*/
-
-	text := strings.TrimSpace(pr.GetTitle())
 
 	var (
 		areas     []string
@@ -230,56 +363,72 @@ func ReleaseNoteFromCommit(commit *github.RepositoryCommit, client *github.Clien
 		isFeature bool
 	)
 
-	// Grab PR area/ labels (falling back to Issue area/ labels)
+	// Grab PR area/ labels (falling back to the area/ labels of the Issues)
+	issueLabels := labelsOfIssues(issues)
 	areas = StringsWithPrefix(GetPRLabels(pr), "area/")
-	if issue != nil && len(areas) == 0 {
-		areas = StringsWithPrefix(GetIssueLabels(issue), "area/")
+	if len(areas) == 0 {
+		areas = StringsWithPrefix(issueLabels, "area/")
 	}
 
-	// Grab PR kind/ labels (falling back to Issue kind/ labels)
+	// Grab PR kind/ labels (falling back to the kind/ labels of the Issues)
 	kinds = StringsWithPrefix(GetPRLabels(pr), "kind/")
-	if issue != nil && len(kinds) == 0 {
-		kinds = StringsWithPrefix(GetIssueLabels(issue), "kind/")
-	}
-
-	if HasString(kinds, "feature") {
-		isFeature = true
-	} else if HasString(kinds, "bug") {
-		isFeature = false
+	if len(kinds) == 0 {
+		kinds = StringsWithPrefix(issueLabels, "kind/")
 	}
 
-	// Else fallback to looking at Issue labels
-	if issue != nil {
-		issueLabels := GetIssueLabels(issue)
-		if HasString(issueLabels, "feature request") || HasString(issueLabels, "enhancement") {
-			isFeature = true
-			if !HasString(kinds, "feature") {
-				kinds = append(kinds, "feature")
-			}
-		} else if HasString(issueLabels, "bug") {
-			isFeature = false
-			if !HasString(kinds, "bug") {
-				kinds = append(kinds, "bug")
-			}
+	// Add the kinds that the Issue labels map to, such as "feature request"
+	for _, kind := range c.config.issueKinds(issueLabels) {
+		if !HasString(kinds, kind) {
+			kinds = append(kinds, kind)
 		}
 	}
+	isFeature = HasString(kinds, "feature")
 
+	// Prefer the URLs GitHub gives us, which are correct for any repository and
+	// GitHub Enterprise instance, and build them from the config otherwise
 	author := pr.GetUser().GetLogin()
-	authorUrl := fmt.Sprintf("https://github.com/%s", author)
-	prUrl := fmt.Sprintf("https://github.com/netdata/netdata/pull/%d", pr.GetNumber())
+	authorUrl := pr.GetUser().GetHTMLURL()
+	if authorUrl == "" {
+		authorUrl = fmt.Sprintf("%s/%s", c.webURL, author)
+	}
+	prUrl := pr.GetHTMLURL()
+	if prUrl == "" {
+		prUrl = fmt.Sprintf("%s/%s/%s/pull/%d", c.webURL, c.org, c.repo, pr.GetNumber())
+	}
+	// a release-note-action-required block is as good as the label
+	labels := GetPRLabels(pr)
+	actionRequired := IsActionRequired(pr)
+	if !actionRequired && hasNoteBlock(ParseNoteBlocks(pr.GetBody()), BlockActionRequired) {
+		actionRequired = true
+		labels = append(labels, "release-note-action-required")
+	}
+
 	IsFeature := isFeature
 	IsDuplicate := false
 	sigsListPretty := prettifySigList(StringsWithPrefix(GetPRLabels(pr), "sig/"))
 	noteSuffix := ""
 
-	if IsActionRequired(pr) || IsFeature {
+	if actionRequired || IsFeature {
 		if sigsListPretty != "" {
 			noteSuffix = fmt.Sprintf("Courtesy of %s", sigsListPretty)
 		}
 	} else if len(StringsWithPrefix(GetPRLabels(pr), "sig/")) > 1 {
 		IsDuplicate = true
 	}
-	markdown := fmt.Sprintf("%s ([#%d](%s), [@%s](%s))", text, pr.GetNumber(), prUrl, author, authorUrl)
+	// the continuation lines of multi-line notes are indented to stay in their
+	// Markdown list item
+	links := []string{
+		fmt.Sprintf("[#%d](%s)", pr.GetNumber(), prUrl),
+		fmt.Sprintf("[@%s](%s)", author, authorUrl),
+	}
+	for i, issue := range issues {
+		link := fmt.Sprintf("[%s](%s)", issue.Ref, issue.Url)
+		if i == 0 {
+			link = "closes " + link
+		}
+		links = append(links, link)
+	}
+	markdown := fmt.Sprintf("%s (%s)", strings.ReplaceAll(text, "\n", "\n  "), strings.Join(links, ", "))
 
 	if noteSuffix != "" {
 		markdown = fmt.Sprintf("%s %s", markdown, noteSuffix)
@@ -296,223 +445,289 @@ func ReleaseNoteFromCommit(commit *github.RepositoryCommit, client *github.Clien
 		SIGs:           StringsWithPrefix(GetPRLabels(pr), "sig/"),
 		Kinds:          kinds,
 		Areas:          areas,
+		Labels:         labels,
+		Issues:         issues,
+		IssueLabels:    issueLabels,
 		Feature:        IsFeature,
 		Duplicate:      IsDuplicate,
-		ActionRequired: IsActionRequired(pr),
+		ActionRequired: actionRequired,
+		Bot:            c.config.excludesAuthor(commit.GetAuthor().GetLogin()) || c.config.excludesAuthor(author),
 	}, nil
 }
 
-// ListCommits lists all commits starting from a given commit SHA and ending at
-// a given commit SHA.
-func ListCommits(client *github.Client, start, end string, opts ...githubApiOption) ([]*github.RepositoryCommit, error) {
-	c := configFromOpts(opts...)
+// squashedPRExp matches the PR number that GitHub appends to the first line of
+// the messages of squashed commits, such as " (#123)".
+var squashedPRExp = regexp.MustCompile(`\s*\(#\d+\)$`)
+
+// noteText returns the text of the release note of a commit and its PR, with
+// the given strategy, or false if it has none.
+func noteText(strategy NoteText, commit *github.RepositoryCommit, pr *github.PullRequest) (string, bool) {
+	// PRs that opted out have no release note, whatever the text is taken from
+	if PROptOut(pr) != "" {
+		return "", false
+	}
+	block, err := NoteTextFromString(pr.GetBody())
+	hasBlock := err == nil
+
+	var text string
+	switch strategy {
+	case NoteTextBlock:
+		text = block
+	case NoteTextCommit:
+		text = commitTitle(commit.GetCommit().GetMessage())
+		text = squashedPRExp.ReplaceAllString(strings.TrimSpace(text), "")
+		// merge commits without a body
+		if text == "" {
+			text = pr.GetTitle()
+		}
+	case NoteTextBlockOrTitle:
+		text = block
+		if !hasBlock {
+			text = pr.GetTitle()
+		}
+	default:
+		text = pr.GetTitle()
+	}
 
-	startCommit, _, err := client.Git.GetCommit(c.ctx, c.org, c.repo, start)
-	if err != nil {
-		return nil, err
+	text = strings.TrimSpace(text)
+	if text == "" || isNoneNote(text) {
+		return "", false
 	}
+	return text, true
+}
 
-	endCommit, _, err := client.Git.GetCommit(c.ctx, c.org, c.repo, end)
-	if err != nil {
-		return nil, err
+// commitTitle returns the first line of a commit message. The first line of
+// the merge commit of a PR, such as "Merge pull request #123 from
+// owner/branch", only names the PR, so the first line of its body is returned
+// instead, which GitHub fills with the title of the PR, or nothing if there is
+// no body.
+func commitTitle(message string) string {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if !mergePRExp.MatchString(lines[0]) {
+		return lines[0]
+	}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// isNoneNote reports whether the text of a release note is NONE or N/A, which
+// means that there is no release note.
+func isNoneNote(text string) bool {
+	text = strings.Trim(strings.TrimSpace(text), `"'.`)
+	return strings.EqualFold(text, "NONE") || strings.EqualFold(text, "N/A")
+}
+
+// OptOut is the reason why a PR opted out of the release notes.
+type OptOut string
+
+const (
+	// OptOutBlock means that the release-note blocks of the PR are NONE or N/A.
+	OptOutBlock OptOut = "NONE release-note block"
+
+	// OptOutLabel means that the PR has the ReleaseNoteNoneLabel.
+	OptOutLabel OptOut = "release-note-none label"
+
+	// OptOutCommand means that the PR description has a /release-note-none
+	// command on a line of its own.
+	OptOutCommand OptOut = "/release-note-none command"
+)
+
+// ReleaseNoteNoneLabel is the label of the PRs that opted out of the release
+// notes.
+const ReleaseNoteNoneLabel = "release-note-none"
+
+// releaseNoteNoneExp matches a /release-note-none command.
+var releaseNoteNoneExp = regexp.MustCompile(`(?im)^\s*/release-note-none\s*$`)
+
+// PROptOut returns the reason why a PR opted out of the release notes, or
+// nothing if it didn't. A PR opts out with release-note blocks whose text is
+// NONE or N/A, regardless of its dev-release-note blocks, with the ReleaseNoteNoneLabel, or with a /release-note-none
+// command. The HTML comments of PR templates, which often explain how to opt
+// out, are ignored.
+func PROptOut(pr *github.PullRequest) OptOut {
+	if pr == nil {
+		return ""
+	}
+	if HasString(GetPRLabels(pr), ReleaseNoteNoneLabel) {
+		return OptOutLabel
 	}
 
-	clo := &github.CommitsListOptions{
-		SHA:   c.branch,
-		Since: *startCommit.Committer.Date,
-		Until: *endCommit.Committer.Date,
-		ListOptions: github.ListOptions{
-			Page:    1,
-			PerPage: 100,
-		},
+	blocks := ParseNoteBlocks(pr.GetBody())
+	none := false
+	for _, block := range blocks {
+		// notes for developers aren't part of the release notes
+		if block.Text == "" || block.Info == BlockDevReleaseNote {
+			continue
+		}
+		if !isNoneNote(block.Text) {
+			none = false
+			break
+		}
+		none = true
+	}
+	if none {
+		return OptOutBlock
 	}
 
-	commits, resp, err := client.Repositories.ListCommits(c.ctx, c.org, c.repo, clo)
+	if releaseNoteNoneExp.MatchString(htmlCommentExp.ReplaceAllString(pr.GetBody(), "")) {
+		return OptOutCommand
+	}
+	return ""
+}
+
+// ListCommits lists all commits reachable from the end revision but not from the
+// start revision, which is the equivalent of `git log start..end`. Since the
+// range is resolved by ancestry rather than by date, rebased, cherry-picked or
+// merged history is handled correctly. Both revisions may be anything accepted
+// by ResolveRevision. Commits are returned oldest first.
+func ListCommits(source Source, start, end string, opts ...githubApiOption) ([]*github.RepositoryCommit, error) {
+	c := configFromOpts(opts...)
+
+	startSHA, err := ResolveRevision(source, start, opts...)
 	if err != nil {
 		return nil, err
 	}
-	clo.ListOptions.Page++
 
-	for clo.ListOptions.Page <= resp.LastPage {
-		commitPage, _, err := client.Repositories.ListCommits(c.ctx, c.org, c.repo, clo)
-		if err != nil {
-			return nil, err
-		}
-		for _, commit := range commitPage {
-			commits = append(commits, commit)
-		}
-		clo.ListOptions.Page++
+	endSHA, err := ResolveRevision(source, end, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	return commits, nil
+	return source.ListCommits(c.ctx, c.org, c.repo, startSHA, endSHA)
 }
 
 // ListCommitsWithNotes list commits that have release notes starting from a
-// given commit SHA and ending at a given commit SHA. This function is similar
-// to ListCommits except that only commits with tagged release notes are
-// returned.
+// given revision and ending at a given revision. This function is similar
+// to ListCommits except that only the commits of PRs that didn't opt out of
+// the release notes, as decided by PROptOut, are returned. The commits of the
+// ExcludeAuthors are left out before their PRs are fetched, unless they are
+// collected in a section of their own.
 func ListCommitsWithNotes(
-	client *github.Client,
+	source Source,
 	logger log.Logger,
 	start,
 	end string,
 	opts ...githubApiOption,
 ) ([]*github.RepositoryCommit, error) {
-	filteredCommits := []*github.RepositoryCommit{}
+	opts = append(opts[:len(opts):len(opts)], WithLogger(logger))
+	c := configFromOpts(opts...)
 
-	commits, err := ListCommits(client, start, end, opts...)
-	fmt.Fprintf(os.Stderr, "no. of commits: %d\n", len(commits))
+	commits, err := ListCommits(source, start, end, opts...)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, commit := range commits {
-		pr, err := PRFromCommit(client, commit, opts...)
-		if err != nil {
-			if err.Error() == "no matches found when parsing PR from commit" {
-				fmt.Fprintf(os.Stderr, "no PR found for %s\n", commit.GetSHA())
-				continue
-			}
+	level.Info(logger).Log("msg", "listed the commits in the range", "commits", len(commits))
+	c.audit.add(commits)
+
+	// each commit is evaluated once, by a worker, and the commits with notes are
+	// stored by index so that they stay in commit order
+	results := make([]*github.RepositoryCommit, len(commits))
+	errs := make([]error, len(commits))
+	forEach(c.concurrency, len(commits), func(i int) {
+		commit := commits[i]
+		logger := log.With(logger, "sha", commit.GetSHA())
+		excludeAs := func(decision Decision, reason string) {
+			level.Info(logger).Log(
+				"msg", "excluding commit from the release notes",
+				"reason", reason,
+			)
+			c.audit.decide(commit.GetSHA(), decision, reason)
 		}
-
-		// Skip PRs with associated Issues whoose labels contain `no changelog`.
-		if issue, err := IssueFromPR(client, pr, opts...); err == nil {
-			if HasString(GetPRLabels(pr), "no changelog") {
-				fmt.Fprintf(os.Stderr,
-					"skipping pr #d with 'no changelog' PR labels",
-					*pr.Number,
-				)
-				continue
-			} else if HasString(GetIssueLabels(issue), "no changelog") {
-				fmt.Fprintf(os.Stderr,
-					"skipping pr #d with 'no changelog' Issue labels #%d",
-					*pr.Number, *issue.Number,
-				)
-				continue
-			}
+		exclude := func(reason string) {
+			excludeAs(DecisionExcluded, reason)
 		}
 
-		// exclusionFilters is a list of regular expressions that match commits that
-		// do NOT contain release notes. Notably, this is all of the variations of
-		// "release note none" that appear in the commit log.
-		exclusionFilters := []string{
-			"```release-note\\r\\nNONE",
-			"```release-note\\r\\n\\s+NONE",
-			"```release-note\\r\\nNONE",
-			"```release-note\\r\\n\"NONE\"",
-			"```release-note\\r\\nNone",
-			"```release-note\\r\\nnone",
-			"```release-note\\r\\nN/A",
-			"```release-note\\r\\n\\r\\n```",
-			"```release-note\\r\\n```",
-			"/release-note-none",
-			"\\r\\n\\r\\nNONE",
-			"```NONE\\r\\n```",
-			"```release-note \\r\\nNONE\\r\\n```",
-			"NONE\\r\\n```",
-			"\\r\\nNone",
-			"\\r\\nNONE\\r\\n",
+		if login := commit.GetAuthor().GetLogin(); !c.config.collectsBots() && c.config.excludesAuthor(login) {
+			excludeAs(DecisionBot, fmt.Sprintf("authored by the excluded author %s", login))
+			return
 		}
 
-		excluded := false
-
-		for _, filter := range exclusionFilters {
-			match, err := regexp.MatchString(filter, pr.GetBody())
-			if err != nil {
-				return nil, err
+		pr, err := PRFromCommit(source, commit, opts...)
+		if err != nil {
+			if errors.Is(err, ErrNoPR) {
+				exclude("no PR found")
+				return
 			}
-			if match {
-				excluded = true
-				break
+			if c.strict {
+				errs[i] = err
+				return
 			}
+			level.Error(logger).Log(
+				"msg", "error getting the PR of a commit",
+				"err", err,
+			)
+			c.audit.decide(commit.GetSHA(), DecisionError, err.Error())
+			return
 		}
-
-		if excluded {
-			fmt.Fprintf(os.Stderr, "excluding %s\n", commit.GetSHA())
-			continue
-		}
-
-		// Similarly, now that the known not-release-notes are filtered out, we can
-		// use some patterns to find actual release notes.
-		inclusionFilters := []string{
-			".*",
-			"release-note",
-			"Does this PR introduce a user-facing change?",
+		c.audit.update(commit.GetSHA(), func(r *AuditRecord) { r.PrNumber = pr.GetNumber() })
+		logger = log.With(logger, "pr", pr.GetNumber())
+
+		// Skip PRs that, or whose associated Issues, have one of the configured
+		// skip labels, such as `no changelog`.
+		issues, err := IssuesFromPR(source, pr, opts...)
+		if err != nil && !errors.Is(err, ErrNoIssue) {
+			if c.strict {
+				errs[i] = err
+				return
+			}
+			level.Warn(logger).Log(
+				"msg", "error getting the issues of a PR",
+				"err", err,
+			)
 		}
-
-		for _, filter := range inclusionFilters {
-			match, err := regexp.MatchString(filter, pr.GetBody())
-			if err != nil {
-				return nil, err
+		c.audit.update(commit.GetSHA(), func(r *AuditRecord) {
+			for _, issue := range issues {
+				r.Issues = append(r.Issues, issue.Ref)
 			}
-			if match {
-				filteredCommits = append(filteredCommits, commit)
+		})
+		if label := firstOf(GetPRLabels(pr), c.config.SkipLabels); label != "" {
+			exclude(fmt.Sprintf("PR #%d has the skip label %q", pr.GetNumber(), label))
+			return
+		}
+		for _, issue := range issues {
+			if label := firstOf(issue.Labels, c.config.SkipLabels); label != "" {
+				exclude(fmt.Sprintf("issue %s of PR #%d has the skip label %q", issue.Ref, pr.GetNumber(), label))
+				return
 			}
 		}
-	}
 
-	return filteredCommits, nil
-}
+		// Every other PR has a release note, unless it opted out of them
+		if reason := PROptOut(pr); reason != "" {
+			exclude(fmt.Sprintf("PR #%d opted out with a %s", pr.GetNumber(), reason))
+			return
+		}
 
-// IssueFromPR returns an API Issue Request for the first matching
-// Issue that Closes/Fixes or Resolve an Issue from parsing the PR's Body.
-// This is useful for going from a commit log to the associated issues
-// it either addresses, closes or fixes (which contains useful info such
-// the type of issue the Comit/PR was fixing/closing as well as labels specific
-// to the issue and not necessarily the pull request).
-func IssueFromPR(client *github.Client, pr *github.PullRequest, opts ...githubApiOption) (*github.Issue, error) {
-	c := configFromOpts(opts...)
+		c.audit.decide(commit.GetSHA(), DecisionIncluded, "")
+		results[i] = commit
+	})
 
-	exp := regexp.MustCompile(`(?i)(` + CloseIssueKeywords + `).*#(?P<number>\d+)`)
-	match := exp.FindStringSubmatch(pr.GetBody())
-	if len(match) == 0 {
-		return nil, errors.New("no matches found when parsing Issue from PR")
-	}
-	result := map[string]string{}
-	for i, name := range exp.SubexpNames() {
-		if i != 0 && name != "" {
-			result[name] = match[i]
+	// in strict mode, the first error in commit order aborts the run
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
 		}
 	}
-	number, err := strconv.Atoi(result["number"])
-	if err != nil {
-		return nil, err
+
+	filteredCommits := []*github.RepositoryCommit{}
+	for _, commit := range results {
+		if commit != nil {
+			filteredCommits = append(filteredCommits, commit)
+		}
 	}
 
-	// Given the PR number that we've now converted to an integer, get the PR from
-	// the API
-	issue, _, err := client.Issues.Get(c.ctx, c.org, c.repo, number)
-	return issue, err
+	return filteredCommits, nil
 }
 
 // PRFromCommit return an API Pull Request struct given a commit struct. This is
 // useful for going from a commit log to the PR (which contains useful info such
 // as labels).
-func PRFromCommit(client *github.Client, commit *github.RepositoryCommit, opts ...githubApiOption) (*github.PullRequest, error) {
+func PRFromCommit(source Source, commit *github.RepositoryCommit, opts ...githubApiOption) (*github.PullRequest, error) {
 	c := configFromOpts(opts...)
-
-	// Thankfully k8s-merge-robot commits the PR number consistently. If this ever
-	// stops being true, this definitely won't work anymore.
-	exp := regexp.MustCompile(`\(#(?P<number>\d+)\)`)
-	match := exp.FindStringSubmatch(*commit.Commit.Message)
-	if len(match) == 0 {
-		return nil, errors.New("no matches found when parsing PR from commit")
-	}
-	result := map[string]string{}
-	for i, name := range exp.SubexpNames() {
-		if i != 0 && name != "" {
-			result[name] = match[i]
-		}
-	}
-	number, err := strconv.Atoi(result["number"])
-	if err != nil {
-		return nil, err
-	}
-
-	// Given the PR number that we've now converted to an integer, get the PR from
-	// the API
-	pr, _, err := client.PullRequests.Get(c.ctx, c.org, c.repo, number)
-	return pr, err
+	return source.PullRequestForCommit(c.ctx, c.org, c.repo, commit)
 }
 
 // GetIssueLabels is a helper for fetching all labels on an Issue
@@ -557,63 +772,19 @@ func IsActionRequired(pr *github.PullRequest) bool {
 	return false
 }
 
-// filterCommits is a helper that allows you to filter a set of commits by
-// applying a set of regular expressions over the commit messages. If include is
-// true, only commits that match at least one expression are returned. If include
-// is false, only commits that match 0 of the expressions are returned.
-func filterCommits(
-	client *github.Client,
-	logger log.Logger,
-	commits []*github.RepositoryCommit,
-	filters []string,
-	include bool,
-	opts ...githubApiOption,
-) ([]*github.RepositoryCommit, error) {
-	filteredCommits := []*github.RepositoryCommit{}
-	for _, commit := range commits {
-		body := commit.GetCommit().GetMessage()
-		if commit.GetAuthor().GetLogin() == "k8s-merge-robot" {
-			pr, err := PRFromCommit(client, commit, opts...)
-			if err != nil {
-				level.Info(logger).Log(
-					"msg", "error getting PR from k8s-merge-robot commit",
-					"err", err,
-					"sha", commit.GetSHA(),
-				)
-				continue
-			}
-			body = pr.GetBody()
-		}
-
-		skip := false
-		for _, filter := range filters {
-			match, err := regexp.MatchString(filter, body)
-			if err != nil {
-				return nil, err
-			}
-			if match && !include || !match && include {
-				skip = true
-				break
-			}
-		}
-		if skip {
-			continue
-		}
-
-		filteredCommits = append(filteredCommits, commit)
-	}
-
-	return filteredCommits, nil
-}
-
 // configFromOpts is an internal helper for turning a set of functional options
 // into a populated *githubApiConfig struct with consistent defaults.
 func configFromOpts(opts ...githubApiOption) *githubApiConfig {
 	c := &githubApiConfig{
-		ctx:    context.Background(),
-		org:    "netdata",
-		repo:   "netdata",
-		branch: "master",
+		ctx:         context.Background(),
+		org:         "netdata",
+		repo:        "netdata",
+		branch:      "master",
+		webURL:      "https://github.com",
+		concurrency: 4,
+		config:      DefaultConfig(),
+		noteText:    NoteTextTitle,
+		logger:      log.NewNopLogger(),
 	}
 
 	for _, opt := range opts {
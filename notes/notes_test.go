@@ -1,42 +1,19 @@
 package notes
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"strings"
 	"testing"
 
+	"github.com/go-kit/kit/log"
 	"github.com/google/go-github/github"
 	"github.com/kolide/kit/logutil"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/oauth2"
 )
 
-const (
-	v1_10_0        = "fc32d2f3698e36b93322a3465f63a14e9f0eaead"
-	v1_11_0_alpha1 = "4761788b2afa42a4573a6794902eb93fe666d5c5"
-	v1_11_0_alpha2 = "ed9b25c90241b2b8a1fa10b96381c57f99ca952a"
-	v1_11_0_beta1  = "4e3b2843df571c3b80c834d7c23bc6da1a22aab8"
-	v1_11_0_beta2  = "be2cfcf9e44b5162a294e977329d6c8194748c4e"
-	v1_11_0_rc1    = "8745ea56e3f1f3ad20050c1762eb6ba6f7786675"
-	v1_11_0_rc2    = "d0a17cb4bbdf608559f257a76acfaa9acb054903"
-	v1_11_0_rc3    = "931fc3b3aef9d679436978529fc7065d75352671"
-	v1_11_0        = "91e7b4fd31fcd3d5f436da26c980becec37ceefe"
-)
-
-func githubClient(t *testing.T) *github.Client {
-	token, tokenSet := os.LookupEnv("GITHUB_TOKEN")
-	if !tokenSet {
-		t.Skip("GITHUB_TOKEN is not set")
-	}
-
-	ctx := context.Background()
-	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	))
-	return github.NewClient(httpClient)
-}
-
 func TestConfigFromOpts(t *testing.T) {
 	// fake config with an override for the org
 	c := configFromOpts(
@@ -47,41 +24,144 @@ func TestConfigFromOpts(t *testing.T) {
 	require.Equal(t, "marpaia", c.org)
 
 	// test the default value
-	require.Equal(t, "kubernetes", c.repo)
+	require.Equal(t, "netdata", c.repo)
 }
 
 func TestGitHubAPIOperations(t *testing.T) {
-	client := githubClient(t)
+	_, source := newFakeGitHub(t)
 	logger := logutil.NewCLILogger(true)
 
-	// there were 48 commits between v1.11.0-rc3 and v1.11.0
-	commits, err := ListCommits(client, v1_11_0_rc3, v1_11_0)
+	// there were 12 commits between v1.0.0 and v1.1.0
+	commits, err := ListCommits(source, "v1.0.0", "v1.1.0")
 	require.NoError(t, err)
-	require.Len(t, commits, 55)
+	require.Len(t, commits, 12)
 
-	// there were 4 commits with release notes between v1.11.0-rc3 and v1.11.0
-	commits, err = ListCommitsWithNotes(client, logger, v1_11_0_rc3, v1_11_0)
+	// 8 of them have release notes: the commit without a PR, the PR labeled "no
+	// changelog", the PR with a NONE release note and the commit by netdatabot
+	// are left out
+	commits, err = ListCommitsWithNotes(source, logger, "v1.0.0", "v1.1.0")
 	require.NoError(t, err)
-	require.Len(t, commits, 4)
+	require.Len(t, commits, 8)
 
 	for _, commit := range commits {
-		// the string "release-note" must be in the commit message
-		require.Contains(t, *commit.Commit.Message, "release-note")
+		require.NotContains(t, []string{mergeCommit, noChangelogCommit, noneCommit, botCommit}, commit.GetSHA())
 
 		// each commit must have an associated PR
-		pr, err := PRFromCommit(client, commit)
+		_, err := PRFromCommit(source, commit)
 		require.NoError(t, err)
 
-		// the PR must have labels
-		require.NotEmpty(t, pr.Labels)
-
 		// the commit must produce a release note
-		note, err := ReleaseNoteFromCommit(commit, client)
+		note, err := ReleaseNoteFromCommit(commit, source)
 		require.NoError(t, err)
 		require.NotContains(t, note.Text, "\r")
 	}
 }
 
+func TestReleaseNoteText(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		noteText NoteText
+		sha      string
+		expected string
+	}{
+		// the PR of sigCommit has a release-note block, and the PR of
+		// featureCommit doesn't
+		{NoteTextTitle, sigCommit, "Speed up the web server static files"},
+		{NoteTextBlock, sigCommit, "Serve the static files of the dashboard\nfrom memory"},
+		{NoteTextBlock, featureCommit, ""},
+		{NoteTextBlockOrTitle, sigCommit, "Serve the static files of the dashboard\nfrom memory"},
+		{NoteTextBlockOrTitle, featureCommit, "Add Prometheus remote write exporter"},
+		{NoteTextCommit, sigCommit, "Speed up the web server static files"},
+
+		// the first line of merge commits only names the PR, so the first line
+		// of their body is taken
+		{NoteTextCommit, mergePRCommit, "Fix crash in apps.plugin on FreeBSD"},
+
+		// a NONE block leaves the PR out whatever the strategy is
+		{NoteTextTitle, noneCommit, ""},
+		{NoteTextBlock, noneCommit, ""},
+		{NoteTextBlockOrTitle, noneCommit, ""},
+		{NoteTextCommit, noneCommit, ""},
+	}
+
+	for _, tc := range cases {
+		commit, err := source.GetCommit(ctx, "netdata", "netdata", tc.sha)
+		require.NoError(t, err)
+		note, err := ReleaseNoteFromCommit(commit, source, WithNoteText(tc.noteText))
+
+		if tc.expected == "" {
+			require.True(t, errors.Is(err, ErrNoNote), "%s %s: %v", tc.noteText, tc.sha, err)
+			require.Nil(t, note, "%s %s", tc.noteText, tc.sha)
+			continue
+		}
+		require.NoError(t, err)
+		require.NotNil(t, note, "%s %s", tc.noteText, tc.sha)
+		require.Equal(t, tc.expected, note.Text, "%s %s", tc.noteText, tc.sha)
+		// multi-line notes stay in their Markdown list item
+		require.Contains(t, note.Markdown, strings.ReplaceAll(tc.expected, "\n", "\n  ")+" ([#")
+	}
+}
+
+func TestCommitTitle(t *testing.T) {
+	cases := map[string]string{
+		"Add Prometheus remote write exporter (#101)":                                  "Add Prometheus remote write exporter (#101)",
+		"Merge pull request #102 from bob/fix-freebsd\n\nFix crash in apps.plugin":     "Fix crash in apps.plugin",
+		"Merge pull request #102 from bob/fix-freebsd\r\n\r\nFix crash\r\n\r\nDetails": "Fix crash",
+		"Merge pull request #102 from bob/fix-freebsd":                                 "",
+		"Merge branch 'master' into fix-freebsd\n\nConflicts":                          "Merge branch 'master' into fix-freebsd",
+	}
+	for message, expected := range cases {
+		require.Equal(t, expected, commitTitle(message), message)
+	}
+}
+
+func TestPROptOut(t *testing.T) {
+	cases := map[string]struct {
+		body     string
+		labels   []string
+		expected OptOut
+	}{
+		"note":              {body: "```release-note\r\nAdd an exporter\r\n```"},
+		"no block":          {body: "Fixes #90"},
+		"empty block":       {body: "```release-note\r\n```"},
+		"none":              {body: "```release-note\r\nNONE\r\n```", expected: OptOutBlock},
+		"quoted none":       {body: "```release-note\n\"None\"\n```", expected: OptOutBlock},
+		"n/a":               {body: "```release-note\nN/A\n```", expected: OptOutBlock},
+		"none blocks":       {body: "```release-note\nNONE\n```\n```dev-release-note\nnone\n```", expected: OptOutBlock},
+		"note and none":     {body: "```release-note\nAdd an exporter\n```\n```dev-release-note\nNONE\n```"},
+		"none and dev note": {body: "```release-note\nNONE\n```\n```dev-release-note\nUse Go modules\n```", expected: OptOutBlock},
+		"dev none":          {body: "```dev-release-note\nNONE\n```"},
+		"none text":         {body: "Nothing to note.\r\nNone of the tests changed."},
+		"label":             {body: "```release-note\nAdd an exporter\n```", labels: []string{"release-note-none"}, expected: OptOutLabel},
+		"command":           {body: "Tidy up.\r\n/release-note-none\r\n", expected: OptOutCommand},
+		"inline command":    {body: "Use /release-note-none to opt out"},
+		"commented":         {body: "<!-- Comment /release-note-none or write NONE below:\n```release-note\nNONE\n```\n-->\nTidy up."},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pr := &github.PullRequest{Body: &tc.body}
+			for _, label := range tc.labels {
+				pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label)})
+			}
+			require.Equal(t, tc.expected, PROptOut(pr))
+		})
+	}
+	require.Equal(t, OptOut(""), PROptOut(nil))
+}
+
+func TestParseNoteText(t *testing.T) {
+	for _, s := range []string{"block", "title", "commit", "block-or-title"} {
+		noteText, err := ParseNoteText(s)
+		require.NoError(t, err)
+		require.Equal(t, NoteText(s), noteText)
+	}
+	_, err := ParseNoteText("body")
+	require.Error(t, err)
+}
+
 func TestStripActionRequired(t *testing.T) {
 	notes := []string{
 		"[action required] The note text",
@@ -105,20 +185,132 @@ func TestStripStar(t *testing.T) {
 }
 
 func TestReleaseNoteParsing(t *testing.T) {
-	client := githubClient(t)
-	commitsWithNote := []string{
-		"5f750c593f94027896d683d32cf86cfbb2c8ce7e",
-		"26083c3d0934caef6f44978384b96a6315fff875",
-		"c585d13e36f4f8f7368ba35cef27fca271bc7083",
-		"1355e6b27713bbbdd3e5975e6fa815368f205c5d",
-	}
+	_, source := newFakeGitHub(t)
 	ctx := context.Background()
 
-	for _, sha := range commitsWithNote {
-		fmt.Println(sha)
-		commit, _, err := client.Repositories.GetCommit(ctx, "kubernetes", "kubernetes", sha)
+	cases := map[string]*ReleaseNote{
+		featureCommit: {
+			Text:     "Add Prometheus remote write exporter",
+			PrNumber: 101,
+			Kinds:    []string{"feature"},
+			Areas:    []string{"exporting"},
+			Feature:  true,
+		},
+		bugFixCommit: {
+			Text:     "Fix crash in apps.plugin on FreeBSD",
+			PrNumber: 102,
+			Kinds:    []string{"bug"},
+			Areas:    []string{"collectors"},
+		},
+		actionCommit: {
+			Text:           "Remove the deprecated [global] history option",
+			PrNumber:       107,
+			ActionRequired: true,
+		},
+		duplicateCommit: {
+			Text:      "Improve the health alarms of the web log collector",
+			PrNumber:  110,
+			SIGs:      []string{"health", "web"},
+			Duplicate: true,
+		},
+	}
+
+	for sha, expected := range cases {
+		commit, err := source.GetCommit(ctx, "netdata", "netdata", sha)
 		require.NoError(t, err)
-		_, err = ReleaseNoteFromCommit(commit, client)
+		note, err := ReleaseNoteFromCommit(commit, source)
 		require.NoError(t, err)
+
+		require.Equal(t, sha, note.Commit)
+		require.Equal(t, expected.Text, note.Text)
+		require.Equal(t, expected.PrNumber, note.PrNumber)
+		require.Equal(t, fmt.Sprintf("https://github.com/netdata/netdata/pull/%d", expected.PrNumber), note.PrUrl)
+		require.Equal(t, "https://github.com/"+note.Author, note.AuthorUrl)
+		require.ElementsMatch(t, expected.Kinds, note.Kinds)
+		require.ElementsMatch(t, expected.Areas, note.Areas)
+		require.ElementsMatch(t, expected.SIGs, note.SIGs)
+		require.Equal(t, expected.Feature, note.Feature)
+		require.Equal(t, expected.Duplicate, note.Duplicate)
+		require.Equal(t, expected.ActionRequired, note.ActionRequired)
+	}
+}
+
+func TestReleaseNoteWebURL(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	opts := []githubApiOption{
+		WithOrg("acme"),
+		WithRepo("widgets"),
+		WithWebURL("https://github.example.com/"),
+	}
+	sha := "c0ffee5d6f1a7a3d4e0b9c8a2f4e6d1b3a5c7e9f"
+
+	// the responses of GitHub Enterprise may leave out the URLs, which are
+	// built from the web URL, the org and the repo then
+	commit, err := source.GetCommit(context.Background(), "acme", "widgets", sha)
+	require.NoError(t, err)
+	note, err := ReleaseNoteFromCommit(commit, source, opts...)
+	require.NoError(t, err)
+	require.Equal(t, sha, note.Commit)
+	require.Equal(t, "https://github.example.com/acme/widgets/pull/7", note.PrUrl)
+	require.Equal(t, "https://github.example.com/grace", note.AuthorUrl)
+	require.Len(t, note.Issues, 1)
+	require.Equal(t, "https://github.example.com/acme/widgets/issues/3", note.Issues[0].Url)
+	require.Equal(t, "Add a dial widget ("+
+		"[#7](https://github.example.com/acme/widgets/pull/7), "+
+		"[@grace](https://github.example.com/grace), "+
+		"closes [#3](https://github.example.com/acme/widgets/issues/3))", note.Markdown)
+}
+
+func TestStrict(t *testing.T) {
+	source := newFakeSource(
+		map[string][]string{"c1": nil, "c2": {"c1"}, "c3": {"c2"}},
+		map[string]string{"v1.0.0": "c1", "v1.1.0": "c3"},
+	)
+	// one of the issues that the PR of c2 closes can't be fetched, and c3 has
+	// no note
+	source.prs["c2"] = &github.PullRequest{
+		Number: github.Int(2),
+		Title:  github.String("Fix the build"),
+		Body:   github.String("Fixes #1, #4"),
+	}
+	source.issues[4] = &github.Issue{Number: github.Int(4)}
+	source.prs["c3"] = &github.PullRequest{
+		Number: github.Int(3),
+		Title:  github.String("Tidy up"),
+		Body:   github.String("```release-note\nNONE\n```"),
 	}
+	logger := logutil.NewCLILogger(true)
+
+	notes, err := ListReleaseNotes(source, logger, "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	require.Len(t, notes[0].Issues, 1)
+	require.Equal(t, 4, notes[0].Issues[0].Number)
+
+	_, err = ListReleaseNotes(source, logger, "v1.0.0", "v1.1.0", WithStrict(true))
+	require.EqualError(t, err, "error getting issue netdata/netdata#1: no issue #1")
+
+	commit, err := source.GetCommit(context.Background(), "netdata", "netdata", "c3")
+	require.NoError(t, err)
+	_, err = ReleaseNoteFromCommit(commit, source, WithStrict(true))
+	require.True(t, errors.Is(err, ErrNoNote), err)
+}
+
+func TestLogging(t *testing.T) {
+	_, source := newFakeGitHub(t)
+	buf := &bytes.Buffer{}
+	logger := log.NewLogfmtLogger(buf)
+
+	// the messages about a commit have its SHA and the number of its PR
+	commit, err := source.GetCommit(context.Background(), "netdata", "netdata", docsCommit)
+	require.NoError(t, err)
+	_, err = ReleaseNoteFromCommit(commit, source, WithLogger(logger))
+	require.NoError(t, err)
+	require.Equal(t, "level=debug sha="+docsCommit+" pr=103 msg=\"no issue found for the PR\"\n", buf.String())
+
+	buf.Reset()
+	_, err = ListCommitsWithNotes(source, logger, "v1.0.0", "v1.1.0", WithConcurrency(1))
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "level=info sha="+noChangelogCommit+" pr=106 msg=\"excluding commit from the release notes\"")
+	require.Contains(t, buf.String(), "level=info sha="+mergeCommit+" msg=\"excluding commit from the release notes\" reason=\"no PR found\"\n")
 }
@@ -0,0 +1,314 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/go-github/github"
+)
+
+const (
+	// serverErrorBackoff is how long to wait before the first retry of a request
+	// that failed with a 5xx status. The wait doubles with every attempt.
+	serverErrorBackoff = time.Second
+
+	// secondaryRateLimitBackoff is how long to wait before retrying a request that
+	// hit a secondary (abuse) rate limit without saying when to retry. GitHub
+	// recommends waiting at least a minute. The wait doubles with every attempt.
+	secondaryRateLimitBackoff = time.Minute
+)
+
+// sleep waits for d to elapse or for ctx to be done, whichever happens first.
+// It is a variable so that tests don't have to wait.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RateLimitTransport is an http.RoundTripper for GitHub API clients that keeps
+// track of the remaining rate limit quota. Rather than failing, requests wait
+// until the quota resets when it is exhausted, back off when a secondary rate
+// limit is hit, and GET and HEAD requests are retried when GitHub responds with
+// a 5xx error. Other requests, such as creating a release, may have been
+// carried out despite the error, so they aren't retried. Neither are requests
+// whose body can't be sent again.
+//
+// Since go-github refuses to make requests while it knows the quota to be
+// exhausted, the transport also waits for the reset before returning the
+// response that used up the quota.
+type RateLimitTransport struct {
+	base       http.RoundTripper
+	logger     log.Logger
+	maxRetries int
+
+	mu     sync.Mutex
+	quotas map[string]github.Rate
+}
+
+// NewRateLimitTransport wraps the base transport of a GitHub API client, which
+// is http.DefaultTransport if nil. Requests are retried at most maxRetries times
+// after a 5xx error or a secondary rate limit, while waiting for the primary
+// rate limit to reset doesn't count as a retry.
+func NewRateLimitTransport(base http.RoundTripper, logger log.Logger, maxRetries int) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{
+		base:       base,
+		logger:     logger,
+		maxRetries: maxRetries,
+		quotas:     map[string]github.Rate{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	resource := rateLimitResource(req.URL.Path)
+
+	for attempt := 0; ; {
+		if err := t.waitForQuota(ctx, resource); err != nil {
+			return nil, err
+		}
+
+		r, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		t.track(resource, resp)
+
+		retry, err := t.checkResponse(req, resp, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if retry == nil || !canRewind(req) {
+			// if this request used up the quota, go-github would refuse to make
+			// the next one, so the reset is awaited before returning
+			if err := t.waitForQuota(ctx, resource); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+		if !retry.primaryRateLimit {
+			if attempt >= t.maxRetries {
+				return resp, nil
+			}
+			attempt++
+		}
+		resp.Body.Close()
+
+		level.Warn(t.logger).Log(
+			"msg", "retrying GitHub API request",
+			"reason", retry.reason,
+			"wait", retry.wait,
+			"attempt", attempt,
+			"url", req.URL.Path,
+		)
+		if err := sleep(ctx, retry.wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retry describes why a request is retried, and how long to wait before doing
+// so.
+type retry struct {
+	reason string
+	wait   time.Duration
+
+	// primaryRateLimit is set when waiting for the primary rate limit to reset,
+	// which doesn't count against the maximum number of retries
+	primaryRateLimit bool
+}
+
+// checkResponse decides whether a request should be retried given its response.
+// It returns nil if the response should be returned to the client as is.
+func (t *RateLimitTransport) checkResponse(req *http.Request, resp *http.Response, attempt int) (*retry, error) {
+	if resp.StatusCode < 400 {
+		return nil, nil
+	}
+
+	// go-github classifies the error from the response body, so it is buffered
+	// to still be readable by the client when the response is returned
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	check := *resp
+	check.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	secondary := &retry{
+		reason: "secondary rate limit exceeded",
+		wait:   backoff(secondaryRateLimitBackoff, attempt),
+	}
+
+	switch e := github.CheckResponse(&check).(type) {
+	case *github.RateLimitError:
+		return &retry{
+			reason:           "rate limit exceeded",
+			wait:             untilReset(e.Rate),
+			primaryRateLimit: true,
+		}, nil
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			secondary.wait = *e.RetryAfter
+		}
+		return secondary, nil
+	case *github.ErrorResponse:
+		status := resp.StatusCode
+		if (status == http.StatusForbidden || status == http.StatusTooManyRequests) && isSecondaryRateLimit(resp, e) {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				secondary.wait = time.Duration(seconds) * time.Second
+			}
+			return secondary, nil
+		}
+		if status >= 500 && isIdempotent(req.Method) {
+			return &retry{
+				reason: http.StatusText(status),
+				wait:   backoff(serverErrorBackoff, attempt),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// track records the rate limit quota reported by a response, and logs the
+// progress through the quota every time another tenth of it has been used.
+func (t *RateLimitTransport) track(resource string, resp *http.Response) {
+	rate := parseRate(resp)
+	if rate.Limit == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	previous, seen := t.quotas[resource]
+	t.quotas[resource] = rate
+	t.mu.Unlock()
+
+	step := rate.Limit / 10
+	if seen && step > 0 && previous.Remaining/step != rate.Remaining/step {
+		level.Info(t.logger).Log(
+			"msg", "GitHub API rate limit quota",
+			"resource", resource,
+			"remaining", rate.Remaining,
+			"limit", rate.Limit,
+			"reset", rate.Reset.Time.Format(time.RFC3339),
+		)
+	}
+}
+
+// waitForQuota blocks until the reset of the rate limit if the quota for the
+// resource is known to be exhausted.
+func (t *RateLimitTransport) waitForQuota(ctx context.Context, resource string) error {
+	t.mu.Lock()
+	rate, seen := t.quotas[resource]
+	t.mu.Unlock()
+
+	if !seen || rate.Remaining > 0 || !time.Now().Before(rate.Reset.Time) {
+		return nil
+	}
+
+	wait := untilReset(rate)
+	level.Warn(t.logger).Log(
+		"msg", "GitHub API rate limit quota exhausted, waiting for it to reset",
+		"resource", resource,
+		"wait", wait,
+	)
+	return sleep(ctx, wait)
+}
+
+// isIdempotent reports whether a request with the given method can be retried
+// after a server error without risking doing it twice.
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// canRewind reports whether a request can be sent again, which is the case if
+// it has no body, or if its body can be read again with GetBody.
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of req that can be sent again, with a fresh body
+// if the request has one. Requests that can't be rewound are returned as is,
+// which is only safe for their first attempt.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// parseRate reads the rate limit headers of a GitHub API response.
+func parseRate(resp *http.Response) github.Rate {
+	rate := github.Rate{}
+	rate.Limit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	rate.Remaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); reset != 0 {
+		rate.Reset = github.Timestamp{Time: time.Unix(reset, 0)}
+	}
+	return rate
+}
+
+// rateLimitResource returns the rate limit that a request counts against.
+func rateLimitResource(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+// isSecondaryRateLimit reports whether a 403 or 429 response was caused by a
+// secondary rate limit that go-github didn't recognize as an abuse rate limit.
+func isSecondaryRateLimit(resp *http.Response, e *github.ErrorResponse) bool {
+	return resp.Header.Get("Retry-After") != "" ||
+		strings.Contains(strings.ToLower(e.Message), "secondary rate limit")
+}
+
+// untilReset returns how long it is until the rate limit quota resets, with a
+// second to spare for clock skew.
+func untilReset(rate github.Rate) time.Duration {
+	wait := time.Until(rate.Reset.Time) + time.Second
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// backoff returns the exponentially increasing wait before the given retry
+// attempt, starting at base.
+func backoff(base time.Duration, attempt int) time.Duration {
+	return base << uint(attempt)
+}
@@ -0,0 +1,149 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedClient returns a GitHub client that talks to a server responding
+// with the given handlers in turn, and records how long the client slept.
+func rateLimitedClient(t *testing.T, handlers ...http.HandlerFunc) (*github.Client, *[]time.Duration) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, requests < len(handlers), "unexpected request %d", requests)
+		handlers[requests](w, r)
+		requests++
+	}))
+	t.Cleanup(server.Close)
+
+	slept := &[]time.Duration{}
+	realSleep := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		*slept = append(*slept, d)
+		return nil
+	}
+	t.Cleanup(func() { sleep = realSleep })
+
+	client := github.NewClient(&http.Client{
+		Transport: NewRateLimitTransport(nil, log.NewNopLogger(), 2),
+	})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client, slept
+}
+
+func respond(status int, headers map[string]string, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestRateLimitTransportRetriesServerErrors(t *testing.T) {
+	client, slept := rateLimitedClient(t,
+		respond(http.StatusBadGateway, nil, `{"message": "Server Error"}`),
+		respond(http.StatusInternalServerError, nil, `{"message": "Server Error"}`),
+		respond(http.StatusOK, nil, `{"number": 42}`),
+	)
+
+	pr, _, err := client.PullRequests.Get(context.Background(), "netdata", "netdata", 42)
+	require.NoError(t, err)
+	require.Equal(t, 42, pr.GetNumber())
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *slept)
+}
+
+func TestRateLimitTransportGivesUpAfterMaxRetries(t *testing.T) {
+	client, slept := rateLimitedClient(t,
+		respond(http.StatusBadGateway, nil, `{"message": "Server Error"}`),
+		respond(http.StatusBadGateway, nil, `{"message": "Server Error"}`),
+		respond(http.StatusBadGateway, nil, `{"message": "Server Error"}`),
+	)
+
+	_, resp, err := client.PullRequests.Get(context.Background(), "netdata", "netdata", 42)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Len(t, *slept, 2)
+}
+
+func TestRateLimitTransportWaitsForReset(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	exhausted := map[string]string{
+		"X-RateLimit-Limit":     "5000",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+	}
+	client, slept := rateLimitedClient(t,
+		respond(http.StatusForbidden, exhausted, `{"message": "API rate limit exceeded for 127.0.0.1."}`),
+		respond(http.StatusForbidden, exhausted, `{"message": "API rate limit exceeded for 127.0.0.1."}`),
+		respond(http.StatusForbidden, exhausted, `{"message": "API rate limit exceeded for 127.0.0.1."}`),
+		respond(http.StatusOK, map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999"}, `{"number": 42}`),
+	)
+
+	// waiting for the quota to reset doesn't count against the retries
+	pr, _, err := client.PullRequests.Get(context.Background(), "netdata", "netdata", 42)
+	require.NoError(t, err)
+	require.Equal(t, 42, pr.GetNumber())
+	require.True(t, len(*slept) >= 3)
+	for _, d := range *slept {
+		require.InDelta(t, time.Hour.Seconds(), d.Seconds(), 5)
+	}
+}
+
+func TestRateLimitTransportBacksOffOnSecondaryRateLimits(t *testing.T) {
+	client, slept := rateLimitedClient(t,
+		respond(http.StatusForbidden, map[string]string{"Retry-After": "30"}, `{"message": "You have exceeded a secondary rate limit."}`),
+		respond(http.StatusForbidden, nil, `{"message": "You have exceeded a secondary rate limit.", "documentation_url": "https://developer.github.com/v3/#abuse-rate-limits"}`),
+		respond(http.StatusOK, nil, `{"number": 42}`),
+	)
+
+	pr, _, err := client.PullRequests.Get(context.Background(), "netdata", "netdata", 42)
+	require.NoError(t, err)
+	require.Equal(t, 42, pr.GetNumber())
+	require.Equal(t, []time.Duration{30 * time.Second, 2 * time.Minute}, *slept)
+}
+
+func TestRateLimitTransportOnlyRetriesIdempotentRequests(t *testing.T) {
+	client, slept := rateLimitedClient(t,
+		respond(http.StatusBadGateway, nil, `{"message": "Server Error"}`),
+	)
+
+	// the release may have been created despite the error
+	_, resp, err := client.Repositories.CreateRelease(context.Background(), "netdata", "netdata", &github.RepositoryRelease{TagName: github.String("v1.1.0")})
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Empty(t, *slept)
+}
+
+func TestRateLimitTransportDoesNotResendReadBodies(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		respond(http.StatusForbidden, map[string]string{"Retry-After": "30"}, `{"message": "You have exceeded a secondary rate limit."}`)(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	// a body without GetBody has been read by the first attempt
+	req, err := http.NewRequest(http.MethodPost, server.URL, ioutil.NopCloser(strings.NewReader(`{}`)))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+
+	resp, err := NewRateLimitTransport(nil, log.NewNopLogger(), 2).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, 1, requests)
+}
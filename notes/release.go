@@ -0,0 +1,120 @@
+package notes
+
+import (
+	"github.com/google/go-github/github"
+)
+
+// Release is a GitHub Release to publish release notes as.
+type Release struct {
+	// Tag is the name of the tag that the release is for. Releases are looked
+	// up by their tag, so there is only ever one release per tag.
+	Tag string
+
+	// Name is the title of the release, which defaults to the tag.
+	Name string
+
+	// Body is the description of the release, such as the rendered notes.
+	Body string
+
+	// Target is the commit that the tag is created at if it doesn't exist yet,
+	// such as the SHA of the end revision of the notes. It defaults to the tip
+	// of the configured branch.
+	Target string
+
+	// Draft creates the release as a draft, which isn't visible to the public
+	// until it is published from the GitHub UI. The draft state of a release
+	// that already exists is left as it is.
+	Draft bool
+}
+
+// ReleaseAction is what PublishRelease did, or would do in a dry run, to
+// publish a release.
+type ReleaseAction string
+
+const (
+	// ReleaseCreated means that there was no release for the tag yet.
+	ReleaseCreated ReleaseAction = "created"
+
+	// ReleaseUpdated means that the release for the tag had a different name
+	// or body.
+	ReleaseUpdated ReleaseAction = "updated"
+
+	// ReleaseUnchanged means that the release for the tag was up to date.
+	ReleaseUnchanged ReleaseAction = "unchanged"
+)
+
+// PublishRelease creates the GitHub Release for a tag in the configured
+// repository, or updates its name and body if it already exists. It is
+// idempotent, so the release isn't touched when it is already up to date. In a
+// dry run, nothing is changed, but the action that would be taken is returned
+// along with the existing release, if there is one.
+//
+// A new release is marked as a prerelease if its tag is a semver prerelease,
+// and its tag is created at the release's target if it doesn't exist yet.
+func PublishRelease(client *github.Client, release *Release, dryRun bool, opts ...githubApiOption) (ReleaseAction, *github.RepositoryRelease, error) {
+	c := configFromOpts(opts...)
+
+	name := release.Name
+	if name == "" {
+		name = release.Tag
+	}
+
+	existing, err := findRelease(client, release.Tag, c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if existing == nil {
+		if dryRun {
+			return ReleaseCreated, nil, nil
+		}
+		target := release.Target
+		if target == "" {
+			target = c.branch
+		}
+		v, ok := parseSemver(release.Tag)
+		created, _, err := client.Repositories.CreateRelease(c.ctx, c.org, c.repo, &github.RepositoryRelease{
+			TagName:         github.String(release.Tag),
+			TargetCommitish: github.String(target),
+			Name:            github.String(name),
+			Body:            github.String(release.Body),
+			Draft:           github.Bool(release.Draft),
+			Prerelease:      github.Bool(ok && v.pre != ""),
+		})
+		return ReleaseCreated, created, err
+	}
+
+	if existing.GetName() == name && existing.GetBody() == release.Body {
+		return ReleaseUnchanged, existing, nil
+	}
+	if dryRun {
+		return ReleaseUpdated, existing, nil
+	}
+	updated, _, err := client.Repositories.EditRelease(c.ctx, c.org, c.repo, existing.GetID(), &github.RepositoryRelease{
+		Name: github.String(name),
+		Body: github.String(release.Body),
+	})
+	return ReleaseUpdated, updated, err
+}
+
+// findRelease returns the release for a tag, or nil if there is none. Releases
+// are listed rather than fetched by tag, since draft releases can't be.
+func findRelease(client *github.Client, tag string, c *githubApiConfig) (*github.RepositoryRelease, error) {
+	lo := &github.ListOptions{
+		Page:    1,
+		PerPage: 100,
+	}
+	for lo.Page != 0 {
+		releases, resp, err := client.Repositories.ListReleases(c.ctx, c.org, c.repo, lo)
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			if release.GetTagName() == tag {
+				return release, nil
+			}
+		}
+		lo.Page = resp.NextPage
+	}
+	return nil, nil
+}
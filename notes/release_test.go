@@ -0,0 +1,97 @@
+package notes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReleases is a GitHub API server that keeps the releases of a repository
+// in memory.
+type fakeReleases struct {
+	releases []*github.RepositoryRelease
+	writes   int
+}
+
+func (f *fakeReleases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const path = "/repos/netdata/netdata/releases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == path:
+		json.NewEncoder(w).Encode(f.releases)
+	case r.Method == http.MethodPost && r.URL.Path == path:
+		f.writes++
+		release := &github.RepositoryRelease{}
+		json.NewDecoder(r.Body).Decode(release)
+		release.ID = github.Int64(int64(len(f.releases) + 1))
+		f.releases = append(f.releases, release)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, path+"/"):
+		f.writes++
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, path+"/"))
+		release := f.releases[id-1]
+		json.NewDecoder(r.Body).Decode(release)
+		json.NewEncoder(w).Encode(release)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPublishRelease(t *testing.T) {
+	fake := &fakeReleases{releases: []*github.RepositoryRelease{{
+		ID:      github.Int64(1),
+		TagName: github.String("v1.0.0"),
+		Name:    github.String("v1.0.0"),
+		Body:    github.String("The first release"),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	release := &Release{Tag: "v1.1.0-rc.1", Body: "## New Features\n", Target: v1_1_0, Draft: true}
+
+	// a dry run changes nothing
+	action, existing, err := PublishRelease(client, release, true)
+	require.NoError(t, err)
+	require.Equal(t, ReleaseCreated, action)
+	require.Nil(t, existing)
+	require.Equal(t, 0, fake.writes)
+
+	action, created, err := PublishRelease(client, release, false)
+	require.NoError(t, err)
+	require.Equal(t, ReleaseCreated, action)
+	require.Equal(t, "v1.1.0-rc.1", created.GetName())
+	require.Equal(t, v1_1_0, created.GetTargetCommitish())
+	require.True(t, created.GetDraft())
+	require.True(t, created.GetPrerelease())
+	require.Equal(t, 1, fake.writes)
+
+	// publishing the same notes again is a no-op
+	action, _, err = PublishRelease(client, release, false)
+	require.NoError(t, err)
+	require.Equal(t, ReleaseUnchanged, action)
+	require.Equal(t, 1, fake.writes)
+
+	// while new notes update the existing release, drafts included
+	release.Body = "## New Features\n\n- Add a feature\n"
+	action, _, err = PublishRelease(client, release, true)
+	require.NoError(t, err)
+	require.Equal(t, ReleaseUpdated, action)
+	require.Equal(t, 1, fake.writes)
+
+	action, updated, err := PublishRelease(client, release, false)
+	require.NoError(t, err)
+	require.Equal(t, ReleaseUpdated, action)
+	require.Equal(t, created.GetID(), updated.GetID())
+	require.Equal(t, release.Body, updated.GetBody())
+	require.Len(t, fake.releases, 2)
+	require.Equal(t, 2, fake.writes)
+}
@@ -0,0 +1,253 @@
+package notes
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// revisionExp splits a revision such as "v1.2.0~3" or "HEAD^2" into the ref it
+// starts from and the ancestry suffixes that follow it.
+var revisionExp = regexp.MustCompile(`^(?P<ref>.*?)(?P<suffix>(?:[~^]\d*)*)$`)
+
+// ancestryExp matches a single `~N` or `^N` ancestry suffix of a revision.
+var ancestryExp = regexp.MustCompile(`[~^]\d*`)
+
+// maxAncestrySteps is the most commits that a revision may walk back, since
+// every step costs a request to resolve.
+const maxAncestrySteps = 1000
+
+// ResolveRevision returns the commit SHA that a revision points to. A revision
+// is a commit SHA, a branch or a tag, optionally followed by git-style `~N` and
+// `^N` suffixes to walk to an ancestor. "HEAD" refers to the tip of the
+// configured branch.
+func ResolveRevision(source Source, rev string, opts ...githubApiOption) (string, error) {
+	c := configFromOpts(opts...)
+
+	ref, steps, err := parseRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	if ref == "HEAD" {
+		ref = c.branch
+	}
+
+	commit, err := source.GetCommit(c.ctx, c.org, c.repo, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "error resolving revision %s", rev)
+	}
+
+	for _, parent := range steps {
+		if parent > len(commit.Parents) {
+			return "", errors.Errorf("revision %s does not exist: commit %s has %d parent(s)", rev, commit.GetSHA(), len(commit.Parents))
+		}
+		commit, err = source.GetCommit(c.ctx, c.org, c.repo, commit.Parents[parent-1].GetSHA())
+		if err != nil {
+			return "", errors.Wrapf(err, "error resolving revision %s", rev)
+		}
+	}
+
+	return commit.GetSHA(), nil
+}
+
+// parseRevision splits a revision into its ref and the list of parents to
+// follow from it, where 1 is the first parent, 2 the second parent and so on.
+// For example "main~2^2" yields "main" and [1, 1, 2].
+func parseRevision(rev string) (string, []int, error) {
+	match := revisionExp.FindStringSubmatch(rev)
+	ref, suffix := match[1], match[2]
+	if ref == "" {
+		return "", nil, errors.Errorf("invalid revision %q", rev)
+	}
+
+	steps := []int{}
+	for _, part := range ancestryExp.FindAllString(suffix, -1) {
+		n := 1
+		if len(part) > 1 {
+			var err error
+			n, err = strconv.Atoi(part[1:])
+			if err != nil || n > maxAncestrySteps {
+				return "", nil, errors.Errorf("invalid revision %q: %s walks back too far", rev, part)
+			}
+		}
+		switch part[0] {
+		case '~':
+			// ~N follows the first parent N times
+			for i := 0; i < n; i++ {
+				steps = append(steps, 1)
+			}
+		case '^':
+			// ^N selects the Nth parent, and ^0 is the commit itself
+			if n > 0 {
+				steps = append(steps, n)
+			}
+		}
+	}
+	if len(steps) > maxAncestrySteps {
+		return "", nil, errors.Errorf("invalid revision %q: walks back more than %d commits", rev, maxAncestrySteps)
+	}
+
+	return ref, steps, nil
+}
+
+// AncestrySource is implemented by the Sources that can tell whether a commit
+// is an ancestor of another one without listing the commits between them.
+// PreviousTag uses it when the Source implements it.
+type AncestrySource interface {
+	// IsAncestor reports whether the commit ancestor is reachable from the
+	// commit sha, both given by their SHAs.
+	IsAncestor(ctx context.Context, org, repo, ancestor, sha string) (bool, error)
+}
+
+// PreviousTag returns the name of the most recent semver tag before the end
+// revision. If end is itself a semver tag, this is the highest tag with a lower
+// version, where pre-releases are only considered when end is a pre-release too.
+// Otherwise, it is the highest versioned tag that end is strictly ahead of.
+func PreviousTag(source Source, end string, opts ...githubApiOption) (string, error) {
+	c := configFromOpts(opts...)
+
+	tags, err := source.ListTags(c.ctx, c.org, c.repo)
+	if err != nil {
+		return "", err
+	}
+
+	type versionedTag struct {
+		name    string
+		version semver
+	}
+	candidates := []versionedTag{}
+	endVersion, endIsTag := parseSemver(end)
+	for _, tag := range tags {
+		version, ok := parseSemver(tag)
+		if !ok {
+			continue
+		}
+		if endIsTag {
+			if !version.less(endVersion) {
+				continue
+			}
+			if version.pre != "" && endVersion.pre == "" {
+				continue
+			}
+		}
+		candidates = append(candidates, versionedTag{name: tag, version: version})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[j].version.less(candidates[i].version)
+	})
+
+	if endIsTag {
+		if len(candidates) > 0 {
+			return candidates[0].name, nil
+		}
+		return "", errors.Errorf("no semver tag found before %s", end)
+	}
+
+	endSHA, err := ResolveRevision(source, end, opts...)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range candidates {
+		tagSHA, err := ResolveRevision(source, candidate.name, opts...)
+		if err != nil {
+			return "", err
+		}
+		if tagSHA == endSHA {
+			continue
+		}
+
+		ahead, err := isAncestor(source, tagSHA, endSHA, opts...)
+		if err != nil {
+			return "", err
+		}
+		if ahead {
+			return candidate.name, nil
+		}
+	}
+
+	return "", errors.Errorf("no semver tag found before %s", end)
+}
+
+// isAncestor reports whether the commit ancestor is reachable from the commit
+// sha, with IsAncestor if the Source is an AncestrySource. Otherwise, it is if
+// none of the commits of ancestor are missing from sha.
+func isAncestor(source Source, ancestor, sha string, opts ...githubApiOption) (bool, error) {
+	c := configFromOpts(opts...)
+
+	if ancestry, ok := source.(AncestrySource); ok {
+		return ancestry.IsAncestor(c.ctx, c.org, c.repo, ancestor, sha)
+	}
+	missing, err := source.ListCommits(c.ctx, c.org, c.repo, sha, ancestor)
+	if err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// semver is a parsed semantic version. Build metadata is discarded since it
+// doesn't take part in version precedence.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// semverExp matches semantic versions with an optional "v" prefix, as tags are
+// usually named.
+var semverExp = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// parseSemver parses a tag name such as "v1.30.0" or "1.30.0-rc.1".
+func parseSemver(s string) (semver, bool) {
+	match := semverExp.FindStringSubmatch(s)
+	if len(match) == 0 {
+		return semver{}, false
+	}
+	v := semver{pre: match[4]}
+	v.major, _ = strconv.Atoi(match[1])
+	v.minor, _ = strconv.Atoi(match[2])
+	v.patch, _ = strconv.Atoi(match[3])
+	return v, true
+}
+
+// less reports whether v has a lower precedence than o, following the rules
+// in https://semver.org/#spec-item-11.
+func (v semver) less(o semver) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	if v.patch != o.patch {
+		return v.patch < o.patch
+	}
+
+	// a pre-release has a lower precedence than the associated normal version
+	if v.pre == "" || o.pre == "" {
+		return v.pre != "" && o.pre == ""
+	}
+
+	vs, ovs := strings.Split(v.pre, "."), strings.Split(o.pre, ".")
+	for i := 0; i < len(vs) && i < len(ovs); i++ {
+		if vs[i] == ovs[i] {
+			continue
+		}
+		vn, vErr := strconv.Atoi(vs[i])
+		on, oErr := strconv.Atoi(ovs[i])
+		switch {
+		case vErr == nil && oErr == nil:
+			return vn < on
+		case vErr == nil:
+			// numeric identifiers sort before alphanumeric ones
+			return true
+		case oErr == nil:
+			return false
+		default:
+			return vs[i] < ovs[i]
+		}
+	}
+	return len(vs) < len(ovs)
+}
@@ -0,0 +1,68 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRevision(t *testing.T) {
+	cases := map[string]struct {
+		ref   string
+		steps []int
+	}{
+		"fc32d2f3698e36b93322a3465f63a14e9f0eaead": {"fc32d2f3698e36b93322a3465f63a14e9f0eaead", []int{}},
+		"v1.30.0":       {"v1.30.0", []int{}},
+		"release/v1.30": {"release/v1.30", []int{}},
+		"HEAD~":         {"HEAD", []int{1}},
+		"HEAD~3":        {"HEAD", []int{1, 1, 1}},
+		"master^2":      {"master", []int{2}},
+		"master~2^2~1^": {"master", []int{1, 1, 2, 1, 1}},
+		"v1.30.0^0":     {"v1.30.0", []int{}},
+	}
+
+	for input, expected := range cases {
+		ref, steps, err := parseRevision(input)
+		require.NoError(t, err)
+		require.Equal(t, expected.ref, ref, input)
+		require.Equal(t, expected.steps, steps, input)
+	}
+
+	for _, input := range []string{"~2", "HEAD~99999999999999999999", "HEAD~1001", "HEAD~1000~1", "HEAD^1001"} {
+		_, _, err := parseRevision(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestSemverOrdering(t *testing.T) {
+	// each version has a lower precedence than the one that follows it
+	versions := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"1.0.0",
+		"v1.0.1",
+		"v1.2.0+build.5",
+		"v1.10.0",
+		"v2.0.0",
+	}
+
+	for i := 0; i < len(versions)-1; i++ {
+		lower, ok := parseSemver(versions[i])
+		require.True(t, ok, versions[i])
+		higher, ok := parseSemver(versions[i+1])
+		require.True(t, ok, versions[i+1])
+
+		require.True(t, lower.less(higher), "%s < %s", versions[i], versions[i+1])
+		require.False(t, higher.less(lower), "%s > %s", versions[i+1], versions[i])
+	}
+
+	for _, tag := range []string{"latest", "v1.2", "release-1.2.0", "v1.2.0.1"} {
+		_, ok := parseSemver(tag)
+		require.False(t, ok, tag)
+	}
+}
@@ -0,0 +1,35 @@
+package notes
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+)
+
+// Source is where the commits, pull requests and issues that release notes are
+// made of come from. GitHubSource implements it on top of the GitHub API, but
+// other backends, or in-memory fakes for testing, can be plugged into
+// ListReleaseNotes just as well.
+//
+// A Source represents its data with the go-github types, which are plain
+// structs. Labels are expected to be set on the pull requests and issues it
+// returns.
+type Source interface {
+	// GetCommit returns the commit that a commit SHA, branch or tag points to,
+	// including its parents.
+	GetCommit(ctx context.Context, org, repo, rev string) (*github.RepositoryCommit, error)
+
+	// ListCommits returns the commits that are reachable from the end commit but
+	// not from the start commit, oldest first. Both are commit SHAs.
+	ListCommits(ctx context.Context, org, repo, start, end string) ([]*github.RepositoryCommit, error)
+
+	// ListTags returns the names of all of the tags in the repository.
+	ListTags(ctx context.Context, org, repo string) ([]string, error)
+
+	// PullRequestForCommit returns the pull request that a commit was merged
+	// in, or ErrNoPR if there is none.
+	PullRequestForCommit(ctx context.Context, org, repo string, commit *github.RepositoryCommit) (*github.PullRequest, error)
+
+	// GetIssue returns the issue with the given number.
+	GetIssue(ctx context.Context, org, repo string, number int) (*github.Issue, error)
+}
@@ -0,0 +1,198 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource is an in-memory Source. Commits are identified by their SHA, and
+// refs point to commit SHAs.
+type fakeSource struct {
+	commits map[string]*github.RepositoryCommit
+	refs    map[string]string
+	tags    []string
+	prs     map[string]*github.PullRequest
+	issues  map[int]*github.Issue
+
+	// prErrs are the errors to fail getting the PRs of commits with
+	prErrs map[string]error
+}
+
+// newFakeSource creates a fakeSource from a history given as a map of commit
+// SHAs to the SHAs of their parents.
+func newFakeSource(history map[string][]string, refs map[string]string, tags ...string) *fakeSource {
+	s := &fakeSource{
+		commits: map[string]*github.RepositoryCommit{},
+		refs:    refs,
+		tags:    tags,
+		prs:     map[string]*github.PullRequest{},
+		issues:  map[int]*github.Issue{},
+		prErrs:  map[string]error{},
+	}
+	for sha, parents := range history {
+		commit := &github.RepositoryCommit{SHA: github.String(sha)}
+		for _, parent := range parents {
+			commit.Parents = append(commit.Parents, github.Commit{SHA: github.String(parent)})
+		}
+		s.commits[sha] = commit
+	}
+	return s
+}
+
+func (s *fakeSource) GetCommit(ctx context.Context, org, repo, rev string) (*github.RepositoryCommit, error) {
+	if sha, ok := s.refs[rev]; ok {
+		rev = sha
+	}
+	commit, ok := s.commits[rev]
+	if !ok {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	return commit, nil
+}
+
+func (s *fakeSource) ListCommits(ctx context.Context, org, repo, start, end string) ([]*github.RepositoryCommit, error) {
+	excluded := s.ancestors(start)
+	commits := []*github.RepositoryCommit{}
+	for _, sha := range s.topological(end) {
+		if _, ok := excluded[sha]; !ok {
+			commits = append(commits, s.commits[sha])
+		}
+	}
+	return commits, nil
+}
+
+// topological returns sha and its ancestors, with parents before children.
+func (s *fakeSource) topological(sha string) []string {
+	seen := map[string]struct{}{}
+	order := []string{}
+	var visit func(sha string)
+	visit = func(sha string) {
+		if _, ok := seen[sha]; ok {
+			return
+		}
+		seen[sha] = struct{}{}
+		for _, parent := range s.commits[sha].Parents {
+			visit(parent.GetSHA())
+		}
+		order = append(order, sha)
+	}
+	visit(sha)
+	return order
+}
+
+func (s *fakeSource) ancestors(sha string) map[string]struct{} {
+	ancestors := map[string]struct{}{}
+	for _, ancestor := range s.topological(sha) {
+		ancestors[ancestor] = struct{}{}
+	}
+	return ancestors
+}
+
+func (s *fakeSource) ListTags(ctx context.Context, org, repo string) ([]string, error) {
+	return s.tags, nil
+}
+
+func (s *fakeSource) PullRequestForCommit(ctx context.Context, org, repo string, commit *github.RepositoryCommit) (*github.PullRequest, error) {
+	if err, ok := s.prErrs[commit.GetSHA()]; ok {
+		return nil, err
+	}
+	pr, ok := s.prs[commit.GetSHA()]
+	if !ok {
+		return nil, errors.Wrapf(ErrNoPR, "commit %s", commit.GetSHA())
+	}
+	return pr, nil
+}
+
+func (s *fakeSource) GetIssue(ctx context.Context, org, repo string, number int) (*github.Issue, error) {
+	issue, ok := s.issues[number]
+	if !ok {
+		return nil, fmt.Errorf("no issue #%d", number)
+	}
+	return issue, nil
+}
+
+// releaseHistory is a history where v1.0.1 was released from a branch, while
+// v1.1.0 was released from master:
+//
+//	c1 - c2 - c3 - c4 - c5 - c6 - c7   master, v1.1.0 at c7, v1.1.0-rc.1 at c5
+//	           \                        v1.0.0 at c3
+//	            b1                      v1.0.1 at b1
+func releaseHistory() *fakeSource {
+	return newFakeSource(
+		map[string][]string{
+			"c1": nil,
+			"c2": {"c1"},
+			"c3": {"c2"},
+			"c4": {"c3"},
+			"c5": {"c4"},
+			"c6": {"c5"},
+			"c7": {"c6"},
+			"b1": {"c3"},
+		},
+		map[string]string{
+			"master":      "c7",
+			"v1.0.0":      "c3",
+			"v1.0.1":      "b1",
+			"v1.1.0-rc.1": "c5",
+			"v1.1.0":      "c7",
+		},
+		"v1.1.0", "v1.1.0-rc.1", "v1.0.1", "v1.0.0", "latest",
+	)
+}
+
+func TestResolveRevision(t *testing.T) {
+	source := releaseHistory()
+	cases := map[string]string{
+		"c4":       "c4",
+		"HEAD":     "c7",
+		"HEAD~2":   "c5",
+		"v1.1.0^":  "c6",
+		"v1.0.1~1": "c3",
+	}
+
+	for rev, expected := range cases {
+		sha, err := ResolveRevision(source, rev)
+		require.NoError(t, err, rev)
+		require.Equal(t, expected, sha, rev)
+	}
+
+	_, err := ResolveRevision(source, "c1^")
+	require.Error(t, err)
+}
+
+func TestPreviousTag(t *testing.T) {
+	source := releaseHistory()
+	cases := map[string]string{
+		// tags are ordered by version
+		"v1.1.0":      "v1.0.1",
+		"v1.1.0-rc.1": "v1.0.1",
+		// other revisions are compared by ancestry
+		"HEAD":   "v1.1.0-rc.1",
+		"HEAD~3": "v1.0.0",
+	}
+
+	for end, expected := range cases {
+		tag, err := PreviousTag(source, end)
+		require.NoError(t, err, end)
+		require.Equal(t, expected, tag, end)
+	}
+
+	_, err := PreviousTag(source, "v1.0.0")
+	require.Error(t, err)
+}
+
+func TestListCommitsFromSource(t *testing.T) {
+	commits, err := ListCommits(releaseHistory(), "v1.0.0", "HEAD")
+	require.NoError(t, err)
+
+	shas := []string{}
+	for _, commit := range commits {
+		shas = append(shas, commit.GetSHA())
+	}
+	require.Equal(t, []string{"c4", "c5", "c6", "c7"}, shas)
+}
@@ -0,0 +1,83 @@
+package notes
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate is the text/template that RenderMarkdown renders documents
+// with. Custom templates are executed with a *Document too, so they have access
+// to every section, by ranging over .Sections or by ID with .Section, and to
+// all of the fields of the notes in them.
+//
+// The "note" template renders a single note as a Markdown list item, and the
+// "section" template renders the notes of a section. Collapsed sections are
+// wrapped in a <details> element. Sections that aren't Combined end with an
+// extra blank line, as the SIGs section always has.
+const DefaultTemplate = `
+{{- define "note" -}}
+- {{ trimPrefix "- " .Markdown }}
+{{ end -}}
+
+{{- define "section" -}}
+{{ if .Grouped -}}
+{{ range .Groups }}### {{ .Title }}
+
+{{ range .Notes }}{{ template "note" . }}{{ end }}
+{{ end }}
+{{ if not .Combined }}
+{{ end -}}
+{{ else -}}
+{{ range .Notes }}{{ template "note" . }}{{ end }}
+
+{{ end -}}
+{{ end -}}
+
+{{- range .Sections }}{{ if not .Empty -}}
+## {{ .Title }}
+
+{{ if .Collapsed -}}
+<details>
+<summary>{{ with len .AllNotes }}{{ . }} {{ if eq . 1 }}change{{ else }}changes{{ end }}{{ end }}</summary>
+
+{{ template "section" . -}}
+</details>
+
+{{ else -}}
+{{ template "section" . -}}
+{{ end -}}
+{{ end }}{{ end -}}
+`
+
+// defaultTemplate is DefaultTemplate, parsed once.
+var defaultTemplate = template.Must(ParseTemplate(DefaultTemplate))
+
+// templateFuncs are the functions available to document templates, on top of
+// the text/template builtins.
+var templateFuncs = template.FuncMap{
+	// prettySIG turns a SIG label such as "api-machinery" into "API Machinery"
+	"prettySIG": prettySIG,
+
+	// join joins a list, such as the Areas of a note, with a separator
+	"join": func(sep string, xs []string) string {
+		return strings.Join(xs, sep)
+	},
+
+	// trimPrefix removes a prefix from a string if it is there
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+}
+
+// ParseTemplate parses the text of a document template, making the template
+// functions available to it.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("release-notes").Funcs(templateFuncs).Parse(text)
+}
+
+// RenderTemplate accepts a Document and writes it to the supplied io.Writer
+// using a template created with ParseTemplate.
+func RenderTemplate(doc *Document, tmpl *template.Template, w io.Writer) error {
+	return tmpl.Execute(w, doc)
+}
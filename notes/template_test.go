@@ -0,0 +1,45 @@
+package notes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	doc, err := CreateDocument([]*ReleaseNote{
+		{
+			Text:     "Fix crash in apps.plugin on FreeBSD",
+			Author:   "bob",
+			PrNumber: 102,
+			Kinds:    []string{"bug"},
+		},
+		{
+			Text:     "Speed up the web server static files",
+			Author:   "erin",
+			PrNumber: 111,
+			Areas:    []string{"web", "performance"},
+			SIGs:     []string{"api-machinery"},
+		},
+	})
+	require.NoError(t, err)
+
+	tmpl, err := ParseTemplate(`{{ range (.Section "bug_fixes").Notes }}* {{ .Text }} (#{{ .PrNumber }} by {{ .Author }})
+{{ end }}{{ range (.Section "sigs").Groups }}{{ .Title }}:{{ range .Notes }} {{ .Text }} [{{ join ", " .Areas }}]{{ end }}
+{{ end }}`)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, RenderTemplate(doc, tmpl, buf))
+	require.Equal(t, `* Fix crash in apps.plugin on FreeBSD (#102 by bob)
+SIG API Machinery: Speed up the web server static files [web, performance]
+`, buf.String())
+
+	_, err = ParseTemplate("{{ .NewFeatures ")
+	require.Error(t, err)
+
+	tmpl, err = ParseTemplate("{{ .NoSuchSection }}")
+	require.NoError(t, err)
+	require.Error(t, RenderTemplate(doc, tmpl, buf))
+}